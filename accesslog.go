@@ -0,0 +1,188 @@
+package gopp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AccessLogEntry is written to the access log as a JSON line for each request.
+type AccessLogEntry struct {
+	Time           time.Time `json:"time"`
+	Method         string    `json:"method"`
+	URLPath        string    `json:"url_path"`
+	Kind           string    `json:"kind"`              // kind of the endpoint like "zip". "sumdb" or "other" for other paths
+	Module         string    `json:"module,omitempty"`  // decoded module path
+	Version        string    `json:"version,omitempty"` // decoded version
+	Status         int       `json:"status"`
+	Bytes          int64     `json:"bytes"`
+	Latency        float64   `json:"latency_seconds"`
+	Upstream       string    `json:"upstream,omitempty"`        // the last requested upstream
+	UpstreamStatus int       `json:"upstream_status,omitempty"` // status code of the last upstream response
+	Cache          string    `json:"cache,omitempty"`           // "hit" or "miss". empty when the cache is not registered
+}
+
+// AddAccessLog registers w which the access log is written to.
+func (p *Proxy) AddAccessLog(w io.Writer) error {
+	if w == nil {
+		return errors.New("unexpected nil")
+	}
+	p.accessLog = &accessLog{enc: json.NewEncoder(w)}
+	return nil
+}
+
+type accessLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (l *accessLog) write(e *AccessLogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enc.Encode(e)
+}
+
+// requestStats collects what happened while the request is served.
+type requestStats struct {
+	mu             sync.Mutex
+	upstream       string
+	upstreamStatus int
+	cacheHit       bool
+	cacheMiss      bool
+}
+
+type requestStatsKey struct{}
+
+func statsFromContext(ctx context.Context) *requestStats {
+	s, _ := ctx.Value(requestStatsKey{}).(*requestStats)
+	return s
+}
+
+// recordCache records the cache result of mr to the request stats and the metrics.
+func (p *Proxy) recordCache(ctx context.Context, mr *ModuleRequest, hit bool) {
+	if s := statsFromContext(ctx); s != nil {
+		s.mu.Lock()
+		if hit {
+			s.cacheHit = true
+		} else {
+			s.cacheMiss = true
+		}
+		s.mu.Unlock()
+	}
+	if p.metrics != nil {
+		result := "miss"
+		if hit {
+			result = "hit"
+		}
+		p.metrics.add(metricCacheRequests, 1, "kind", mr.Kind.String(), "result", result)
+	}
+}
+
+// recordUpstream records the upstream response to the request stats and the metrics.
+func (p *Proxy) recordUpstream(ctx context.Context, up *upstream, resp *http.Response, err error, d time.Duration) {
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	if s := statsFromContext(ctx); s != nil {
+		s.mu.Lock()
+		s.upstream = up.String()
+		s.upstreamStatus = 0
+		if err == nil {
+			s.upstreamStatus = resp.StatusCode
+		}
+		s.mu.Unlock()
+	}
+	if p.metrics != nil {
+		p.metrics.add(metricUpstreamRequests, 1, "upstream", up.String(), "code", code)
+		p.metrics.observe(metricUpstreamDuration, d.Seconds(), "upstream", up.String())
+	}
+}
+
+// observe wraps h to write the access log and collect the metrics.
+func (p *Proxy) observe(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		stats := &requestStats{}
+		r = r.WithContext(context.WithValue(r.Context(), requestStatsKey{}, stats))
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rw, r)
+		latency := time.Since(start)
+
+		e := &AccessLogEntry{
+			Time:    start,
+			Method:  r.Method,
+			URLPath: r.URL.Path,
+			Kind:    "other",
+			Status:  rw.status,
+			Bytes:   rw.bytes,
+			Latency: latency.Seconds(),
+		}
+		mr, err := parseModuleRequest(r.URL.Path)
+		switch {
+		case isSumDBPath(r.URL.Path):
+			e.Kind = "sumdb"
+		case err == nil:
+			e.Kind = mr.Kind.String()
+			e.Module = mr.Path
+			e.Version = mr.Version
+		}
+		stats.mu.Lock()
+		e.Upstream = stats.upstream
+		e.UpstreamStatus = stats.upstreamStatus
+		switch {
+		case stats.cacheMiss:
+			e.Cache = "miss"
+		case stats.cacheHit:
+			e.Cache = "hit"
+		}
+		stats.mu.Unlock()
+
+		if p.accessLog != nil {
+			p.accessLog.write(e)
+		}
+		if p.metrics != nil {
+			p.metrics.add(metricRequests, 1, "kind", e.Kind, "code", strconv.Itoa(e.Status))
+			p.metrics.observe(metricRequestDuration, e.Latency, "kind", e.Kind)
+			p.metrics.add(metricResponseBytes, float64(e.Bytes), "kind", e.Kind)
+			if e.Kind == KindZip.String() && e.Status == http.StatusOK {
+				p.metrics.add(metricModuleDownloads, 1, "module", e.Module)
+			}
+		}
+	})
+}
+
+// responseRecorder records the status code and the number of bytes written.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rw *responseRecorder) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.status = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher.
+func (rw *responseRecorder) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
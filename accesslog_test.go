@@ -0,0 +1,82 @@
+package gopp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestProxy_AddAccessLog(t *testing.T) {
+	p, _, _ := newCacheTestProxy(t, NewMemoryStorage(), time.Minute, http.StatusOK, "zip")
+	if err := p.AddAccessLog(nil); err == nil {
+		t.Error("expected error for nil")
+	}
+	var buf bytes.Buffer
+	if err := p.AddAccessLog(&buf); err != nil {
+		t.Fatal(err)
+	}
+	serve(p, "/github.com/!burnt!sushi/toml/@v/v0.3.1.zip")
+	serve(p, "/github.com/!burnt!sushi/toml/@v/v0.3.1.zip")
+	serve(p, "/github.com/BurntSushi/toml/@v/list")
+	serve(p, "/sumdb/sum.golang.org/supported")
+
+	want := []AccessLogEntry{
+		{
+			Method:         "GET",
+			URLPath:        "/github.com/!burnt!sushi/toml/@v/v0.3.1.zip",
+			Kind:           "zip",
+			Module:         "github.com/BurntSushi/toml",
+			Version:        "v0.3.1",
+			Status:         http.StatusOK,
+			Bytes:          3,
+			Upstream:       "https://localhost",
+			UpstreamStatus: http.StatusOK,
+			Cache:          "miss",
+		},
+		{
+			Method:  "GET",
+			URLPath: "/github.com/!burnt!sushi/toml/@v/v0.3.1.zip",
+			Kind:    "zip",
+			Module:  "github.com/BurntSushi/toml",
+			Version: "v0.3.1",
+			Status:  http.StatusOK,
+			Bytes:   3,
+			Cache:   "hit",
+		},
+		{
+			Method:  "GET",
+			URLPath: "/github.com/BurntSushi/toml/@v/list",
+			Kind:    "other",
+			Status:  http.StatusBadRequest,
+		},
+		{
+			Method:  "GET",
+			URLPath: "/sumdb/sum.golang.org/supported",
+			Kind:    "sumdb",
+			Status:  http.StatusNotFound,
+		},
+	}
+	dec := json.NewDecoder(&buf)
+	for i, w := range want {
+		var got AccessLogEntry
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+		if got.Time.IsZero() || got.Latency <= 0 {
+			t.Errorf("entry %d: time and latency must be set: %+v", i, got)
+		}
+		got.Time, got.Latency = time.Time{}, 0
+		if got.Bytes != w.Bytes && w.Status != http.StatusOK {
+			// the body of the error depends on the error handler.
+			got.Bytes = w.Bytes
+		}
+		if got != w {
+			t.Errorf("entry %d:\nexpected %+v\n but got %+v", i, w, got)
+		}
+	}
+	if dec.More() {
+		t.Error("unexpected entries")
+	}
+}
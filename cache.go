@@ -0,0 +1,152 @@
+package gopp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// errCacheAborted is used to abort storing the body which is not read until EOF.
+var errCacheAborted = errors.New("gopp: cache aborted")
+
+// Cache stores module artifacts to Storage. The artifacts are keyed by the
+// escaped module path and version, so FileStorage has the same layout as
+// GOMODCACHE/cache/download and can be used as GOPROXY=file://dir.
+//
+// .info, .mod, .zip and .ziphash are immutable, so they are cached forever.
+// @latest and @v/list are cached until ttl is expired. ttl <= 0 disables caching them.
+type Cache struct {
+	storage Storage
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// NewCache makes a cache which is stored to s.
+func NewCache(s Storage, ttl time.Duration) (*Cache, error) {
+	if s == nil {
+		return nil, errors.New("unexpected nil")
+	}
+	return &Cache{
+		storage: s,
+		ttl:     ttl,
+		now:     time.Now,
+	}, nil
+}
+
+// AddCache registers cache in front of the upstream GOPROXY.
+func (p *Proxy) AddCache(c *Cache) error {
+	if c == nil {
+		return errors.New("unexpected nil")
+	}
+	p.cache = c
+	return nil
+}
+
+func (c *Cache) name(mr *ModuleRequest) (string, error) {
+	urlPath, err := mr.URLPath()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(urlPath, "/"), nil
+}
+
+func (c *Cache) mutable(mr *ModuleRequest) bool {
+	return mr.Kind == KindLatest || mr.Kind == KindList
+}
+
+// get returns the cached body of mr. ok is false when mr is not cached or expired.
+func (c *Cache) get(ctx context.Context, mr *ModuleRequest) (body io.ReadCloser, ok bool) {
+	if c.mutable(mr) && c.ttl <= 0 {
+		return nil, false
+	}
+	name, err := c.name(mr)
+	if err != nil {
+		return nil, false
+	}
+	if c.mutable(mr) {
+		info, err := c.storage.Stat(ctx, name)
+		if err != nil || c.now().Sub(info.ModTime) > c.ttl {
+			return nil, false
+		}
+	}
+	body, err = c.storage.Get(ctx, name)
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+// put stores data of mr to the storage. storing is best effort, so the error is ignored.
+func (c *Cache) put(ctx context.Context, mr *ModuleRequest, data []byte) {
+	if c.mutable(mr) && c.ttl <= 0 {
+		return
+	}
+	name, err := c.name(mr)
+	if err != nil {
+		return
+	}
+	c.storage.Put(ctx, name, bytes.NewReader(data))
+}
+
+// tee returns body which stores contents of the upstream body to the storage
+// while it is read. The contents are stored only when the body is read until EOF.
+func (c *Cache) tee(ctx context.Context, mr *ModuleRequest, body io.ReadCloser) io.ReadCloser {
+	if c.mutable(mr) && c.ttl <= 0 {
+		return body
+	}
+	name, err := c.name(mr)
+	if err != nil {
+		return body
+	}
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// storing is best effort. the client is served even if it is failed.
+		err := c.storage.Put(ctx, name, pr)
+		pr.CloseWithError(err)
+	}()
+	return &cacheWriter{
+		body: body,
+		pw:   pw,
+		done: done,
+	}
+}
+
+type cacheWriter struct {
+	body io.ReadCloser
+	pw   *io.PipeWriter // nil after finished
+	done chan struct{}
+}
+
+func (cw *cacheWriter) Read(p []byte) (int, error) {
+	n, err := cw.body.Read(p)
+	if n > 0 && cw.pw != nil {
+		if _, werr := cw.pw.Write(p[:n]); werr != nil {
+			cw.finish(werr)
+		}
+	}
+	if err == io.EOF {
+		cw.finish(nil)
+	}
+	return n, err
+}
+
+func (cw *cacheWriter) Close() error {
+	cw.finish(errCacheAborted)
+	return cw.body.Close()
+}
+
+// finish closes the pipe and waits until the storage finishes.
+// err == nil commits the contents.
+func (cw *cacheWriter) finish(err error) {
+	if cw.pw == nil {
+		return
+	}
+	cw.pw.CloseWithError(err)
+	cw.pw = nil
+	<-cw.done
+}
@@ -0,0 +1,182 @@
+package gopp
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "gopp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func newCacheTestProxy(t *testing.T, s Storage, ttl time.Duration, code int, body string) (*Proxy, *Cache, *int) {
+	t.Helper()
+	calls := 0
+	c := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{
+				StatusCode: code,
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+			}, nil
+		},
+	}
+	p, err := NewProxy(c, "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewCache(s, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddCache(cache); err != nil {
+		t.Fatal(err)
+	}
+	return p, cache, &calls
+}
+
+func serve(p *Proxy, urlPath string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", urlPath, nil))
+	return rec
+}
+
+func TestCache_immutable(t *testing.T) {
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		wantFile string
+	}{
+		{
+			name:     "info",
+			urlPath:  "/github.com/!burnt!sushi/toml/@v/v0.3.1.info",
+			body:     `{"Version":"v0.3.1","Time":"2018-08-20T05:11:00Z"}`,
+			wantFile: "github.com/!burnt!sushi/toml/@v/v0.3.1.info",
+		},
+		{
+			name:     "mod",
+			urlPath:  "/github.com/!burnt!sushi/toml/@v/v0.3.1.mod",
+			body:     "module github.com/BurntSushi/toml\n",
+			wantFile: "github.com/!burnt!sushi/toml/@v/v0.3.1.mod",
+		},
+		{
+			name:     "zip",
+			urlPath:  "/github.com/!burnt!sushi/toml/@v/v0.3.1.zip",
+			body:     "zip",
+			wantFile: "github.com/!burnt!sushi/toml/@v/v0.3.1.zip",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+			s, err := NewFileStorage(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, _, calls := newCacheTestProxy(t, s, 0, http.StatusOK, tt.body)
+			first := serve(p, tt.urlPath)
+			second := serve(p, tt.urlPath)
+			if *calls != 1 {
+				t.Errorf("expected 1 upstream call but got %d", *calls)
+			}
+			if first.Body.String() != second.Body.String() {
+				t.Errorf("expected %q but got %q", first.Body.String(), second.Body.String())
+			}
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(tt.wantFile))); err != nil {
+				t.Errorf("expected cache file: %v", err)
+			}
+		})
+	}
+}
+
+func TestCache_ttl(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		elapsed   time.Duration
+		wantCalls int
+	}{
+		{
+			name:      "Fresh",
+			ttl:       time.Minute,
+			elapsed:   30 * time.Second,
+			wantCalls: 1,
+		},
+		{
+			name:      "Expired",
+			ttl:       time.Minute,
+			elapsed:   2 * time.Minute,
+			wantCalls: 2,
+		},
+		{
+			name:      "Disabled",
+			ttl:       0,
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, cache, calls := newCacheTestProxy(t, NewMemoryStorage(), tt.ttl, http.StatusOK, "v0.0.1\nv0.0.2\n")
+			serve(p, "/github.com/pkg/errors/@v/list")
+			cache.now = func() time.Time { return time.Now().Add(tt.elapsed) }
+			rec := serve(p, "/github.com/pkg/errors/@v/list")
+			if *calls != tt.wantCalls {
+				t.Errorf("expected %d upstream calls but got %d", tt.wantCalls, *calls)
+			}
+			if want := "v0.0.1\nv0.0.2\n"; rec.Body.String() != want {
+				t.Errorf("expected %q but got %q", want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCache_notCommitted(t *testing.T) {
+	t.Run("Upstream error", func(t *testing.T) {
+		p, _, calls := newCacheTestProxy(t, NewMemoryStorage(), time.Minute, http.StatusNotFound, "not found")
+		serve(p, "/github.com/pkg/errors/@v/v0.0.1.mod")
+		if rec := serve(p, "/github.com/pkg/errors/@v/v0.0.1.mod"); rec.Code != http.StatusNotFound {
+			t.Errorf("expected %d but got %d", http.StatusNotFound, rec.Code)
+		}
+		if *calls != 2 {
+			t.Errorf("expected 2 upstream calls but got %d", *calls)
+		}
+	})
+	t.Run("Partial read", func(t *testing.T) {
+		p, _, calls := newCacheTestProxy(t, NewMemoryStorage(), time.Minute, http.StatusOK, "zipzipzip")
+		if err := p.AddZipProxyHandler(func(w http.ResponseWriter, r *http.Request, body io.Reader) error {
+			_, err := io.CopyN(w, body, 3)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		serve(p, "/github.com/pkg/errors/@v/v0.0.1.zip")
+		serve(p, "/github.com/pkg/errors/@v/v0.0.1.zip")
+		if *calls != 2 {
+			t.Errorf("expected 2 upstream calls but got %d", *calls)
+		}
+	})
+}
+
+func TestProxy_AddCache(t *testing.T) {
+	p := &Proxy{}
+	if err := p.AddCache(nil); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+	if _, err := NewCache(nil, time.Minute); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
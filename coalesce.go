@@ -0,0 +1,305 @@
+package gopp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// EnableCoalescing deduplicates in-flight upstream requests which have the
+// same url path. Only one request is sent to the upstream and its body is
+// spooled to a temporary file in dir, which is read by every waiting client
+// while it is written. os.TempDir() is used when dir is empty.
+// The request is not coalesced when the temporary file can not be created.
+func (p *Proxy) EnableCoalescing(dir string) error {
+	if dir != "" {
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+	}
+	p.coalescer = &coalescer{
+		dir:     dir,
+		flights: make(map[string]*flight),
+	}
+	return nil
+}
+
+// coalescer deduplicates in-flight upstream requests.
+type coalescer struct {
+	dir string
+
+	mu      sync.Mutex
+	flights map[string]*flight // key -> in-flight request
+}
+
+// flight is an in-flight upstream request.
+type flight struct {
+	ready chan struct{}  // closed when resp or err is set
+	resp  *http.Response // body is read from spool
+	err   error
+	spool *sharedSpool
+}
+
+// do calls fn once for the concurrent calls which have the same key and
+// returns the response which body is shared by them.
+func (c *coalescer) do(ctx context.Context, key string, fn func(ctx context.Context) (*http.Response, error)) (*http.Response, error) {
+	c.mu.Lock()
+	f, joined := c.flights[key]
+	if !joined {
+		sp, err := newSharedSpool(c.dir)
+		if err != nil {
+			// no disk spool is available.
+			c.mu.Unlock()
+			return fn(ctx)
+		}
+		f = &flight{ready: make(chan struct{}), spool: sp}
+		c.flights[key] = f
+	}
+	body, ok := f.spool.newReader(ctx)
+	c.mu.Unlock()
+	if !ok {
+		// every client of the flight has gone away and it is being aborted.
+		return fn(ctx)
+	}
+	if !joined {
+		fctx, cancel := context.WithCancel(detachedContext{parent: ctx})
+		f.spool.setAbandon(cancel)
+		go c.run(fctx, cancel, key, f, fn)
+	}
+
+	select {
+	case <-f.ready:
+	case <-ctx.Done():
+		body.Close()
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		body.Close()
+		return nil, f.err
+	}
+	resp := *f.resp // shallow copy
+	resp.Header = f.resp.Header.Clone()
+	resp.Body = body
+	return &resp, nil
+}
+
+// run requests the upstream by fn and spools the body.
+func (c *coalescer) run(ctx context.Context, cancel context.CancelFunc, key string, f *flight, fn func(ctx context.Context) (*http.Response, error)) {
+	defer cancel()
+	defer c.remove(key, f)
+	resp, err := fn(ctx)
+	if err != nil {
+		f.err = err
+		close(f.ready)
+		f.spool.fill(failedReader{err: err})
+		return
+	}
+	defer resp.Body.Close()
+	shared := *resp
+	shared.Body = nil
+	f.resp = &shared
+	close(f.ready)
+	f.spool.fill(resp.Body)
+}
+
+func (c *coalescer) remove(key string, f *flight) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.flights[key] == f {
+		delete(c.flights, key)
+	}
+}
+
+type failedReader struct{ err error }
+
+func (r failedReader) Read([]byte) (int, error) { return 0, r.err }
+
+// detachedContext keeps the values of parent but is never canceled by parent,
+// so that the coalesced request outlives the client which started it.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (d detachedContext) Value(key interface{}) interface{} { return d.parent.Value(key) }
+
+// sharedSpool is a temporary file which is written by one writer and read by
+// multiple readers while it is written. The file is removed when the writer
+// finished and all readers are closed. The writer is aborted by abandon when
+// all readers are closed before it finishes.
+type sharedSpool struct {
+	f *os.File
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	size    int64 // bytes written
+	err     error // io.EOF when the writer finished successfully
+	refs    int   // number of open readers
+	aborted bool  // all readers were closed before the writer finished
+	removed bool
+	abandon func()
+}
+
+func newSharedSpool(dir string) (*sharedSpool, error) {
+	f, err := ioutil.TempFile(dir, "gopp-flight-")
+	if err != nil {
+		return nil, err
+	}
+	s := &sharedSpool{f: f}
+	s.cond = sync.NewCond(&s.mu)
+	return s, nil
+}
+
+func (s *sharedSpool) setAbandon(abandon func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aborted {
+		abandon()
+		return
+	}
+	s.abandon = abandon
+}
+
+// fill copies r to the file until EOF or error.
+func (s *sharedSpool) fill(r io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := s.f.Write(buf[:n]); werr != nil {
+				err = werr
+			} else {
+				s.mu.Lock()
+				s.size += int64(n)
+				s.cond.Broadcast()
+				s.mu.Unlock()
+			}
+		}
+		if err != nil {
+			s.mu.Lock()
+			s.err = err
+			s.cond.Broadcast()
+			if s.refs == 0 {
+				s.remove()
+			}
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// newReader returns the reader from the beginning of the file. ok is false
+// when the spool is already aborted or removed.
+func (s *sharedSpool) newReader(ctx context.Context) (body io.ReadCloser, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.removed || s.aborted {
+		return nil, false
+	}
+	s.refs++
+	r := &spoolReader{s: s, ctx: ctx, stop: make(chan struct{})}
+	go func() {
+		// wake up Read which is waiting for data when ctx is done.
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.cond.Broadcast()
+			s.mu.Unlock()
+		case <-r.stop:
+		}
+	}()
+	return r, true
+}
+
+// remove removes the file. s.mu must be held.
+func (s *sharedSpool) remove() {
+	if s.removed {
+		return
+	}
+	s.removed = true
+	s.f.Close()
+	os.Remove(s.f.Name())
+}
+
+func (s *sharedSpool) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+	if s.err != nil {
+		s.remove()
+		return
+	}
+	// no one reads the rest of the body.
+	s.aborted = true
+	if s.abandon != nil {
+		s.abandon()
+	}
+}
+
+// spoolReader reads sharedSpool from the beginning.
+type spoolReader struct {
+	s      *sharedSpool
+	ctx    context.Context
+	off    int64
+	stop   chan struct{}
+	closed bool
+}
+
+func (r *spoolReader) Read(p []byte) (int, error) {
+	s := r.s
+	s.mu.Lock()
+	for !r.closed && r.off >= s.size && s.err == nil && r.ctx.Err() == nil {
+		s.cond.Wait()
+	}
+	if r.closed {
+		s.mu.Unlock()
+		return 0, errors.New("read on closed body")
+	}
+	if r.off < s.size {
+		if rest := s.size - r.off; int64(len(p)) > rest {
+			p = p[:rest]
+		}
+		s.mu.Unlock()
+		// the file is never removed while r is open.
+		n, err := s.f.ReadAt(p, r.off)
+		r.off += int64(n)
+		if err == io.EOF && n > 0 {
+			err = nil
+		}
+		return n, err
+	}
+	err := s.err
+	if err == nil {
+		err = r.ctx.Err()
+	}
+	s.mu.Unlock()
+	return 0, err
+}
+
+func (r *spoolReader) Close() error {
+	r.s.mu.Lock()
+	if r.closed {
+		r.s.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.s.mu.Unlock()
+	close(r.stop)
+	r.s.release()
+	return nil
+}
@@ -0,0 +1,279 @@
+package gopp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitReaders waits until n readers join the flight of key.
+func waitReaders(t *testing.T, c *coalescer, key string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		f, ok := c.flights[key]
+		c.mu.Unlock()
+		if ok {
+			f.spool.mu.Lock()
+			refs := f.spool.refs
+			f.spool.mu.Unlock()
+			if refs == n {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d readers did not join the flight of %s", n, key)
+}
+
+// waitEmpty waits until all spool files in dir are removed.
+func waitEmpty(t *testing.T, dir string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("spool files are not removed: %s", files[0].Name())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestProxy_coalescing(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	const n = 10
+	body := strings.Repeat("zip", 100000)
+	release := make(chan struct{})
+	var calls int32
+	client := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/zip"}},
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+			}, nil
+		},
+	}
+	p, err := NewProxy(client, "https://a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.EnableCoalescing(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	const urlPath = "/github.com/pkg/errors/@v/v0.9.1.zip"
+	var wg sync.WaitGroup
+	codes := make([]int, n)
+	bodies := make([]string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec := serve(p, urlPath)
+			codes[i] = rec.Code
+			bodies[i] = rec.Body.String()
+		}(i)
+	}
+	waitReaders(t, p.coalescer, urlPath, n)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected 1 upstream request but got %d", got)
+	}
+	for i := 0; i < n; i++ {
+		if codes[i] != http.StatusOK {
+			t.Errorf("[%d] expected %d but got %d", i, http.StatusOK, codes[i])
+		}
+		if bodies[i] != body {
+			t.Errorf("[%d] unexpected body of %d bytes", i, len(bodies[i]))
+		}
+	}
+	waitEmpty(t, dir)
+
+	// the finished flight is not reused.
+	rec := serve(p, urlPath)
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Errorf("unexpected response %d", rec.Code)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("expected 2 upstream requests but got %d", got)
+	}
+}
+
+func TestCoalescer_error(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	c := &coalescer{dir: dir, flights: make(map[string]*flight)}
+
+	const n = 5
+	wantErr := errors.New("connection refused")
+	release := make(chan struct{})
+	var calls int32
+	fn := func(ctx context.Context) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return nil, wantErr
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = c.do(context.Background(), "/key", fn)
+		}(i)
+	}
+	waitReaders(t, c, "/key", n)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected 1 call but got %d", got)
+	}
+	for i, err := range errs {
+		if err != wantErr {
+			t.Errorf("[%d] expected %v but got %v", i, wantErr, err)
+		}
+	}
+	waitEmpty(t, dir)
+}
+
+func TestCoalescer_cancel(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	c := &coalescer{dir: dir, flights: make(map[string]*flight)}
+
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (*http.Response, error) {
+		<-release
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("body")),
+		}, nil
+	}
+
+	// the first caller starts the flight and goes away.
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.do(ctx, "/key", fn)
+		leaderErr <- err
+	}()
+	waitReaders(t, c, "/key", 1)
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	follower := make(chan result, 1)
+	go func() {
+		resp, err := c.do(context.Background(), "/key", fn)
+		follower <- result{resp, err}
+	}()
+	waitReaders(t, c, "/key", 2)
+	cancel()
+	if err := <-leaderErr; err != context.Canceled {
+		t.Errorf("expected %v but got %v", context.Canceled, err)
+	}
+	close(release)
+
+	res := <-follower
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	got, err := ioutil.ReadAll(res.resp.Body)
+	res.resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "body" {
+		t.Errorf("expected %q but got %q", "body", got)
+	}
+	waitEmpty(t, dir)
+}
+
+func TestCoalescer_abandon(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	c := &coalescer{dir: dir, flights: make(map[string]*flight)}
+
+	started := make(chan struct{})
+	aborted := make(chan struct{})
+	fn := func(ctx context.Context) (*http.Response, error) {
+		close(started)
+		<-ctx.Done()
+		close(aborted)
+		return nil, ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.do(ctx, "/key", fn)
+		done <- err
+	}()
+	<-started
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected %v but got %v", context.Canceled, err)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the upstream request is not aborted when every client has gone away")
+	}
+	waitEmpty(t, dir)
+}
+
+func TestProxy_EnableCoalescing(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{
+		{name: "Dir", dir: dir},
+		{name: "TempDir", dir: ""},
+		{name: "Not exist", dir: filepath.Join(dir, "none"), wantErr: true},
+		{name: "Not dir", dir: file, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProxy(http.DefaultClient, "https://a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = p.EnableCoalescing(tt.dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (p.coalescer == nil) != tt.wantErr {
+				t.Errorf("unexpected coalescer: %v", p.coalescer)
+			}
+		})
+	}
+}
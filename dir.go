@@ -0,0 +1,101 @@
+package gopp
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// DirClient is ProxyClient which serves modules from the local directory
+// laid out like $GOMODCACHE/cache/download. It never accesses the network.
+//
+// @v/list is served from the list file when it exists. Otherwise it is made
+// from the .info files. @latest is not served like the "file://" GOPROXY of
+// the go command.
+type DirClient struct {
+	dir string
+}
+
+var _ ProxyClient = (*DirClient)(nil)
+
+// NewDirClient makes DirClient which serves modules under dir.
+func NewDirClient(dir string) (*DirClient, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &DirClient{dir: dir}, nil
+}
+
+// Do implements ProxyClient. Only the url path of req is used.
+func (d *DirClient) Do(req *http.Request) (*http.Response, error) {
+	mr, err := parseModuleRequest(req.URL.Path)
+	if err != nil {
+		return textResponse(http.StatusBadRequest, err.Error()), nil
+	}
+	if mr.Kind == KindLatest {
+		return textResponse(http.StatusNotFound, "@latest is not supported"), nil
+	}
+	// module path and version are validated by parseModuleRequest,
+	// so that the file name never goes out of d.dir.
+	urlPath, err := mr.URLPath()
+	if err != nil {
+		return textResponse(http.StatusBadRequest, err.Error()), nil
+	}
+	filename := filepath.Join(d.dir, filepath.FromSlash(urlPath))
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) && mr.Kind == KindList {
+		return d.list(req, filepath.Dir(filename))
+	}
+	if os.IsNotExist(err) {
+		return textResponse(http.StatusNotFound, "not found: "+urlPath), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       f,
+		Request:    req,
+	}, nil
+}
+
+// list makes @v/list from the .info files in vdir.
+func (d *DirClient) list(req *http.Request, vdir string) (*http.Response, error) {
+	infos, err := filepath.Glob(filepath.Join(vdir, "*.info"))
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 {
+		return textResponse(http.StatusNotFound, "not found: "+req.URL.Path), nil
+	}
+	versions := make([]string, 0, len(infos))
+	for _, info := range infos {
+		v, err := module.UnescapeVersion(strings.TrimSuffix(filepath.Base(info), ".info"))
+		if err != nil || !semver.IsValid(v) {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.Compare(versions[i], versions[j]) < 0
+	})
+	var b strings.Builder
+	for _, v := range versions {
+		b.WriteString(v + "\n")
+	}
+	resp := textResponse(http.StatusOK, b.String())
+	resp.Request = req
+	return resp, nil
+}
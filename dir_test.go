@@ -0,0 +1,144 @@
+package gopp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func makeTestDownloadDir(t *testing.T, dir string) {
+	t.Helper()
+	writeFiles(t, dir, map[string]string{
+		"github.com/!burnt!sushi/toml/@v/list":        "v0.3.0\nv0.3.1\n",
+		"github.com/!burnt!sushi/toml/@v/v0.3.1.info": `{"Version":"v0.3.1","Time":"2018-08-15T10:47:33Z"}`,
+		"github.com/!burnt!sushi/toml/@v/v0.3.1.mod":  "module github.com/BurntSushi/toml\n",
+		"github.com/!burnt!sushi/toml/@v/v0.3.1.zip":  "zip",
+		"example.com/foo/@v/v1.0.0.info":              `{"Version":"v1.0.0","Time":"2019-01-01T00:00:00Z"}`,
+		"example.com/foo/@v/v0.9.0.info":              `{"Version":"v0.9.0","Time":"2018-01-01T00:00:00Z"}`,
+		"example.com/foo/@v/v1.1.0.mod":               "module example.com/foo\n",
+	})
+}
+
+func TestDirClient(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	makeTestDownloadDir(t, dir)
+	dc, err := NewDirClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			urlPath:  "/github.com/!burnt!sushi/toml/@v/list",
+			wantCode: http.StatusOK,
+			wantBody: "v0.3.0\nv0.3.1\n",
+		},
+		{
+			urlPath:  "/github.com/!burnt!sushi/toml/@v/v0.3.1.info",
+			wantCode: http.StatusOK,
+			wantBody: `{"Version":"v0.3.1","Time":"2018-08-15T10:47:33Z"}`,
+		},
+		{
+			urlPath:  "/github.com/!burnt!sushi/toml/@v/v0.3.1.mod",
+			wantCode: http.StatusOK,
+			wantBody: "module github.com/BurntSushi/toml\n",
+		},
+		{
+			urlPath:  "/github.com/!burnt!sushi/toml/@v/v0.3.1.zip",
+			wantCode: http.StatusOK,
+			wantBody: "zip",
+		},
+		{
+			// list is made from the .info files
+			urlPath:  "/example.com/foo/@v/list",
+			wantCode: http.StatusOK,
+			wantBody: "v0.9.0\nv1.0.0\n",
+		},
+		{
+			urlPath:  "/github.com/!burnt!sushi/toml/@latest",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			urlPath:  "/github.com/!burnt!sushi/toml/@v/v0.3.0.info",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			urlPath:  "/example.org/bar/@v/list",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			urlPath:  "/github.com/BurntSushi/toml/@v/list",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			urlPath:  "/example.com/../foo/@v/list",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.urlPath, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.urlPath, nil)
+			resp, err := dc.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("expected %d but got %d: %s", tt.wantCode, resp.StatusCode, body)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("expected %q but got %q", tt.wantBody, string(body))
+			}
+		})
+	}
+}
+
+func TestNewDirClient(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	writeFiles(t, dir, map[string]string{"file": ""})
+	if _, err := NewDirClient(filepath.Join(dir, "file")); err == nil {
+		t.Error("expected error for the file")
+	}
+	if _, err := NewDirClient(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for the missing directory")
+	}
+}
+
+func TestProxy_fileUpstream(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	makeTestDownloadDir(t, dir)
+
+	c := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			t.Errorf("unexpected request to %s", req.URL)
+			return nil, http.ErrHandlerTimeout
+		},
+	}
+	p, err := NewProxy(c, "file://"+filepath.ToSlash(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(p, "/github.com/!burnt!sushi/toml/@v/v0.3.1.mod")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if want := "module github.com/BurntSushi/toml\n"; rec.Body.String() != want {
+		t.Errorf("expected %q but got %q", want, rec.Body.String())
+	}
+	if rec := serve(p, "/example.org/bar/@v/list"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected %d but got %d", http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,718 @@
+package gopp
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	modzip "golang.org/x/mod/zip"
+)
+
+// DirectClient is ProxyClient which implements the GOPROXY protocol itself
+// like "direct" of GOPROXY. It resolves the repository by go-import meta tags
+// and fetches modules by the local git binary.
+//
+// Only git repositories are supported. Versions which major version does not
+// match the module path (+incompatible) are not listed.
+type DirectClient struct {
+	client  ProxyClient // requests go-import meta tags
+	dir     string      // bare repositories are kept under dir
+	git     string
+	schemes []string // allowed schemes of the repository url
+
+	mu    sync.Mutex
+	roots map[string]*repoRoot   // module path -> repository
+	locks map[string]*sync.Mutex // git dir -> lock
+}
+
+var _ ProxyClient = (*DirectClient)(nil)
+
+// defaultRepoSchemes are the schemes of the repository url which are allowed
+// for go-import meta tags like the go command. Local repositories can not be
+// fetched since meta tags are served by anyone who owns the domain.
+var defaultRepoSchemes = []string{"https", "git+ssh", "ssh"}
+
+// gitProtocols maps the scheme of the repository url to the protocol of
+// GIT_ALLOW_PROTOCOL.
+var gitProtocols = map[string]string{
+	"https":   "https",
+	"git+ssh": "ssh",
+	"ssh":     "ssh",
+	"file":    "file",
+}
+
+// NewDirectClient makes DirectClient. c is used to request go-import meta tags
+// like "https://golang.org/x/net?go-get=1". Fetched repositories are kept
+// under dir.
+func NewDirectClient(c ProxyClient, dir string) (*DirectClient, error) {
+	if c == nil {
+		return nil, errors.New("unexpected nil")
+	}
+	if dir == "" {
+		return nil, errors.New("empty directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return nil, err
+	}
+	return &DirectClient{
+		client:  c,
+		dir:     dir,
+		git:     git,
+		schemes: defaultRepoSchemes,
+		roots:   make(map[string]*repoRoot),
+		locks:   make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// AddDirectClient registers c which is used for "direct" in the upstream GOPROXY list.
+func (p *Proxy) AddDirectClient(c ProxyClient) error {
+	if c == nil {
+		return errors.New("unexpected nil")
+	}
+	p.direct = c
+	return nil
+}
+
+// notFoundError represents that the module or the version does not exist.
+type notFoundError struct {
+	err error
+}
+
+func (e *notFoundError) Error() string { return e.err.Error() }
+
+// Do implements ProxyClient. Only the url path of req is used.
+func (d *DirectClient) Do(req *http.Request) (*http.Response, error) {
+	mr, err := parseModuleRequest(req.URL.Path)
+	if err != nil {
+		return textResponse(http.StatusBadRequest, err.Error()), nil
+	}
+	body, err := d.serve(req.Context(), mr)
+	if err != nil {
+		var nf *notFoundError
+		if errors.As(err, &nf) {
+			return textResponse(http.StatusNotFound, err.Error()), nil
+		}
+		return nil, err
+	}
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       body,
+		Request:    req,
+	}, nil
+}
+
+func textResponse(code int, msg string) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode: code,
+		Header:     http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+		Body:       ioutil.NopCloser(strings.NewReader(msg)),
+	}
+}
+
+func (d *DirectClient) serve(ctx context.Context, mr *ModuleRequest) (io.ReadCloser, error) {
+	switch mr.Kind {
+	case KindList, KindLatest, KindInfo, KindMod, KindZip:
+	default:
+		// e.g. .ziphash is computed by the proxy itself.
+		return nil, &notFoundError{err: fmt.Errorf("%s@%s: unsupported endpoint %s", mr.Path, mr.Version, mr.Kind)}
+	}
+	repo, err := d.repo(ctx, mr.Path)
+	if err != nil {
+		return nil, err
+	}
+	unlock := d.lock(repo.gitDir)
+	defer unlock()
+
+	switch mr.Kind {
+	case KindList:
+		if err := d.fetch(ctx, repo); err != nil {
+			return nil, err
+		}
+		versions, err := d.versions(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(strings.NewReader(strings.Join(versions, "\n") + "\n")), nil
+	case KindLatest:
+		if err := d.fetch(ctx, repo); err != nil {
+			return nil, err
+		}
+		info, err := d.latest(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		return jsonBody(info)
+	}
+
+	commit, err := d.resolve(ctx, repo, mr.Version)
+	if err != nil {
+		return nil, err
+	}
+	switch mr.Kind {
+	case KindInfo:
+		t, err := d.commitTime(ctx, repo, commit)
+		if err != nil {
+			return nil, err
+		}
+		return jsonBody(&Info{Version: mr.Version, Time: t, Origin: repo.origin(commit, mr.Version)})
+	case KindMod:
+		_, gomod, err := d.moduleDir(ctx, repo, commit)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(gomod)), nil
+	case KindZip:
+		return d.zip(ctx, repo, commit, mr.Version)
+	}
+	return nil, fmt.Errorf("unexpected kind: %s", mr.Kind)
+}
+
+func jsonBody(v interface{}) (io.ReadCloser, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// origin returns the provenance of the version which is resolved to commit.
+func (r *repoRoot) origin(commit, version string) *Origin {
+	o := &Origin{
+		VCS:       "git",
+		URL:       r.url,
+		Subdir:    r.codeDir,
+		Hash:      commit,
+		TagPrefix: r.tagPrefix(),
+	}
+	if !isPseudoVersion(version) {
+		o.Ref = "refs/tags/" + r.tagPrefix() + version
+	}
+	return o
+}
+
+// repoRoot represents the repository of the module.
+type repoRoot struct {
+	modPath   string
+	url       string // url of the git repository
+	codeDir   string // directory of the module in the repository without major version suffix
+	pathMajor string // like "/v2"
+	gitDir    string // local bare repository
+}
+
+// tagPrefix returns the prefix of the tags of the module.
+func (r *repoRoot) tagPrefix() string {
+	if r.codeDir == "" {
+		return ""
+	}
+	return r.codeDir + "/"
+}
+
+func (d *DirectClient) repo(ctx context.Context, modPath string) (*repoRoot, error) {
+	d.mu.Lock()
+	repo, ok := d.roots[modPath]
+	d.mu.Unlock()
+	if ok {
+		return repo, nil
+	}
+	imports, err := d.metaImports(ctx, modPath)
+	if err != nil {
+		return nil, err
+	}
+	var found *metaImport
+	for i, mi := range imports {
+		if mi.vcs != "git" {
+			continue
+		}
+		if modPath == mi.prefix || strings.HasPrefix(modPath, mi.prefix+"/") {
+			if found == nil || len(mi.prefix) > len(found.prefix) {
+				found = &imports[i]
+			}
+		}
+	}
+	if found == nil {
+		return nil, &notFoundError{err: fmt.Errorf("%s: no go-import meta tags for git", modPath)}
+	}
+	if err := d.checkRepoURL(found.repoURL); err != nil {
+		return nil, &notFoundError{err: fmt.Errorf("%s: invalid repository url %q: %v", modPath, found.repoURL, err)}
+	}
+	prefix, pathMajor, ok := module.SplitPathVersion(modPath)
+	if !ok {
+		return nil, &notFoundError{err: fmt.Errorf("%s: invalid module path", modPath)}
+	}
+	codeDir := ""
+	if strings.HasPrefix(prefix, found.prefix+"/") {
+		codeDir = strings.TrimPrefix(prefix, found.prefix+"/")
+	}
+	sum := sha256.Sum256([]byte(found.repoURL))
+	repo = &repoRoot{
+		modPath:   modPath,
+		url:       found.repoURL,
+		codeDir:   codeDir,
+		pathMajor: pathMajor,
+		gitDir:    filepath.Join(d.dir, hex.EncodeToString(sum[:])[:32]),
+	}
+	d.mu.Lock()
+	d.roots[modPath] = repo
+	d.mu.Unlock()
+	return repo, nil
+}
+
+// checkRepoURL checks the repository url of go-import meta tags, which is
+// passed to git.
+func (d *DirectClient) checkRepoURL(repoURL string) error {
+	if strings.HasPrefix(repoURL, "-") {
+		return errors.New("leading dash")
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" {
+		return errors.New("no scheme")
+	}
+	allowed := false
+	for _, scheme := range d.schemes {
+		if u.Scheme == scheme {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+	if u.Scheme != "file" && (u.Host == "" || strings.HasPrefix(u.Host, "-")) {
+		return errors.New("invalid host")
+	}
+	return nil
+}
+
+type metaImport struct {
+	prefix, vcs, repoURL string
+}
+
+// metaImports requests "https://<modPath>?go-get=1" and returns go-import meta tags.
+func (d *DirectClient) metaImports(ctx context.Context, modPath string) ([]metaImport, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://"+modPath+"?go-get=1", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// go-import meta tags can be served with non-200 status code.
+	return parseMetaGoImports(resp.Body)
+}
+
+// parseMetaGoImports parses go-import meta tags in the head of html.
+func parseMetaGoImports(r io.Reader) ([]metaImport, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "utf-8", "ascii":
+			return input, nil
+		}
+		return nil, fmt.Errorf("can't decode XML document using charset %q", charset)
+	}
+	dec.Strict = false
+	var imports []metaImport
+	for {
+		t, err := dec.RawToken()
+		if err != nil {
+			if err == io.EOF || len(imports) > 0 {
+				return imports, nil
+			}
+			return nil, err
+		}
+		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return imports, nil
+		}
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return imports, nil
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") {
+			continue
+		}
+		if attrValue(e.Attr, "name") != "go-import" {
+			continue
+		}
+		if f := strings.Fields(attrValue(e.Attr, "content")); len(f) == 3 {
+			imports = append(imports, metaImport{
+				prefix:  f[0],
+				vcs:     f[1],
+				repoURL: f[2],
+			})
+		}
+	}
+}
+
+func attrValue(attrs []xml.Attr, name string) string {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (d *DirectClient) lock(gitDir string) (unlock func()) {
+	d.mu.Lock()
+	l, ok := d.locks[gitDir]
+	if !ok {
+		l = new(sync.Mutex)
+		d.locks[gitDir] = l
+	}
+	d.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+func (d *DirectClient) runGit(ctx context.Context, gitDir string, args ...string) ([]byte, error) {
+	cmd, stderr := d.gitCommand(ctx, gitDir, args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, gitError(cmd, err, stderr)
+	}
+	return out, nil
+}
+
+// gitCommand makes git command. stderr of the command is written to the returned buffer.
+func (d *DirectClient) gitCommand(ctx context.Context, gitDir string, args ...string) (*exec.Cmd, *bytes.Buffer) {
+	if gitDir != "" {
+		args = append([]string{"--git-dir", gitDir}, args...)
+	}
+	cmd := exec.CommandContext(ctx, d.git, args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ALLOW_PROTOCOL="+d.allowProtocol())
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	return cmd, &stderr
+}
+
+func gitError(cmd *exec.Cmd, err error, stderr *bytes.Buffer) error {
+	return fmt.Errorf("git %s: %v: %s", strings.Join(cmd.Args[1:], " "), err, strings.TrimSpace(stderr.String()))
+}
+
+// allowProtocol returns GIT_ALLOW_PROTOCOL which restricts the protocols of
+// git to the allowed schemes, including redirects and submodules.
+func (d *DirectClient) allowProtocol() string {
+	var protocols []string
+	seen := make(map[string]bool)
+	for _, scheme := range d.schemes {
+		proto, ok := gitProtocols[scheme]
+		if !ok || seen[proto] {
+			continue
+		}
+		seen[proto] = true
+		protocols = append(protocols, proto)
+	}
+	return strings.Join(protocols, ":")
+}
+
+// fetch fetches all branches and tags of the repository into the local bare repository.
+func (d *DirectClient) fetch(ctx context.Context, repo *repoRoot) error {
+	if _, err := os.Stat(repo.gitDir); os.IsNotExist(err) {
+		if _, err := d.runGit(ctx, "", "init", "--bare", "--quiet", repo.gitDir); err != nil {
+			return err
+		}
+	}
+	_, err := d.runGit(ctx, repo.gitDir, "fetch", "--quiet", "--force", "--prune", "--tags", "--",
+		repo.url, "+refs/heads/*:refs/heads/*", "+HEAD:refs/remotes/origin/HEAD")
+	if err != nil {
+		return &notFoundError{err: fmt.Errorf("%s: %v", repo.modPath, err)}
+	}
+	return nil
+}
+
+// versions returns sorted versions of the module which are tagged.
+func (d *DirectClient) versions(ctx context.Context, repo *repoRoot) ([]string, error) {
+	out, err := d.runGit(ctx, repo.gitDir, "for-each-ref", "--format=%(refname)", "refs/tags/")
+	if err != nil {
+		return nil, err
+	}
+	prefix := "refs/tags/" + repo.tagPrefix()
+	var versions []string
+	for _, ref := range strings.Fields(string(out)) {
+		if !strings.HasPrefix(ref, prefix) {
+			continue
+		}
+		v := strings.TrimPrefix(ref, prefix)
+		if !semver.IsValid(v) || semver.Canonical(v) != v || isPseudoVersion(v) {
+			continue
+		}
+		if module.CheckPathMajor(v, repo.pathMajor) != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.Compare(versions[i], versions[j]) < 0
+	})
+	return versions, nil
+}
+
+// latest returns the highest tagged version or the pseudo-version of HEAD.
+func (d *DirectClient) latest(ctx context.Context, repo *repoRoot) (*Info, error) {
+	versions, err := d.versions(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	if v := highestVersion(versions); v != "" {
+		commit, err := d.resolve(ctx, repo, v)
+		if err != nil {
+			return nil, err
+		}
+		t, err := d.commitTime(ctx, repo, commit)
+		if err != nil {
+			return nil, err
+		}
+		return &Info{Version: v, Time: t, Origin: repo.origin(commit, v)}, nil
+	}
+	commit, err := d.revParse(ctx, repo, "refs/remotes/origin/HEAD")
+	if err != nil {
+		return nil, &notFoundError{err: fmt.Errorf("%s: no commits", repo.modPath)}
+	}
+	t, err := d.commitTime(ctx, repo, commit)
+	if err != nil {
+		return nil, err
+	}
+	major := "v0"
+	if repo.pathMajor != "" {
+		major = module.PathMajorPrefix(repo.pathMajor)
+	}
+	v := major + ".0.0-" + t.UTC().Format(pseudoVersionTimestampFormat) + "-" + commit[:12]
+	return &Info{Version: v, Time: t, Origin: repo.origin(commit, v)}, nil
+}
+
+func (d *DirectClient) revParse(ctx context.Context, repo *repoRoot, rev string) (string, error) {
+	out, err := d.runGit(ctx, repo.gitDir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// resolve returns the commit hash of version. The repository is fetched
+// only when the version is not found locally.
+func (d *DirectClient) resolve(ctx context.Context, repo *repoRoot, version string) (string, error) {
+	if err := module.CheckPathMajor(version, repo.pathMajor); err != nil {
+		return "", &notFoundError{err: fmt.Errorf("%s@%s: %v", repo.modPath, version, err)}
+	}
+	rev := "refs/tags/" + repo.tagPrefix() + version
+	if isPseudoVersion(version) {
+		rev = pseudoVersionRev(version)
+		// only the short commit hash is accepted. branches and tags are
+		// moving and can not be served as immutable versions.
+		if !shortCommitRE.MatchString(rev) {
+			return "", &notFoundError{err: fmt.Errorf("%s@%s: revision %q is not a short commit hash", repo.modPath, version, rev)}
+		}
+	}
+	commit, err := d.revParse(ctx, repo, rev)
+	if err != nil {
+		if err := d.fetch(ctx, repo); err != nil {
+			return "", err
+		}
+		commit, err = d.revParse(ctx, repo, rev)
+		if err != nil {
+			return "", &notFoundError{err: fmt.Errorf("%s@%s: unknown revision", repo.modPath, version)}
+		}
+	}
+	if isPseudoVersion(version) {
+		if !strings.HasPrefix(commit, rev) {
+			return "", &notFoundError{err: fmt.Errorf("%s@%s: revision resolves to commit %s", repo.modPath, version, commit)}
+		}
+		t, err := d.commitTime(ctx, repo, commit)
+		if err != nil {
+			return "", err
+		}
+		if want := t.UTC().Format(pseudoVersionTimestampFormat); pseudoVersionTime(version) != want {
+			return "", &notFoundError{err: fmt.Errorf("%s@%s: does not match commit time %s", repo.modPath, version, want)}
+		}
+	}
+	return commit, nil
+}
+
+func (d *DirectClient) commitTime(ctx context.Context, repo *repoRoot, commit string) (time.Time, error) {
+	out, err := d.runGit(ctx, repo.gitDir, "log", "-1", "--format=%ct", commit)
+	if err != nil {
+		return time.Time{}, err
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(sec, 0).UTC(), nil
+}
+
+// moduleDir returns the directory of the module in the repository and its go.mod.
+// go.mod is synthesized when the module does not have it.
+func (d *DirectClient) moduleDir(ctx context.Context, repo *repoRoot, commit string) (string, []byte, error) {
+	var candidates []string
+	if repo.pathMajor != "" && !strings.HasPrefix(repo.pathMajor, ".") {
+		// major subdirectory like example.com/foo/v2/go.mod
+		candidates = append(candidates, path.Join(repo.codeDir, repo.pathMajor[1:]))
+	}
+	candidates = append(candidates, repo.codeDir)
+	for _, dir := range candidates {
+		gomod, err := d.runGit(ctx, repo.gitDir, "cat-file", "blob", commit+":"+path.Join(dir, "go.mod"))
+		if err == nil {
+			return dir, gomod, nil
+		}
+	}
+	return repo.codeDir, []byte(fmt.Sprintf("module %s\n", repo.modPath)), nil
+}
+
+// zip builds the module zip of commit by golang.org/x/mod/zip.
+func (d *DirectClient) zip(ctx context.Context, repo *repoRoot, commit, version string) (io.ReadCloser, error) {
+	dir, _, err := d.moduleDir(ctx, repo, commit)
+	if err != nil {
+		return nil, err
+	}
+	tree := commit
+	if dir != "" {
+		tree = commit + ":" + dir
+	}
+	tmpDir, err := ioutil.TempDir("", "gopp-direct-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := d.archive(ctx, repo, tree, tmpDir); err != nil {
+		return nil, err
+	}
+	sf, err := newTempSpoolFile()
+	if err != nil {
+		return nil, err
+	}
+	m := module.Version{Path: repo.modPath, Version: version}
+	if err := modzip.CreateFromDir(sf, m, tmpDir); err != nil {
+		sf.Close()
+		return nil, err
+	}
+	if _, err := sf.Seek(0, io.SeekStart); err != nil {
+		sf.Close()
+		return nil, err
+	}
+	return sf, nil
+}
+
+// extractTar extracts regular files of the tar archive into dir.
+// archive extracts files of tree into dir by git archive. The line endings
+// are kept as they are committed like the go command.
+func (d *DirectClient) archive(ctx context.Context, repo *repoRoot, tree, dir string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd, stderr := d.gitCommand(ctx, repo.gitDir,
+		"-c", "core.autocrlf=input", "-c", "core.eol=lf", "archive", "--format=tar", tree)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := extractTar(dir, stdout); err != nil {
+		// kill git which may be blocked on writing the rest of the archive.
+		cancel()
+		cmd.Wait()
+		return err
+	}
+	// read the padding after the end of the archive.
+	io.Copy(ioutil.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return gitError(cmd, err, stderr)
+	}
+	return nil
+}
+
+func extractTar(dir string, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue // directories are created below. symlinks are not allowed in the module zip.
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("unexpected file path in archive: %s", hdr.Name)
+		}
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+const pseudoVersionTimestampFormat = "20060102150405"
+
+var pseudoVersionRE = regexp.MustCompile(`^v[0-9]+\.(0\.0-|\d+\.\d+-([^+]*\.)?0\.)\d{14}-[A-Za-z0-9]+(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+// shortCommitRE matches the revision of the pseudo-version for git, which is
+// the first 12 characters of the commit hash.
+var shortCommitRE = regexp.MustCompile(`^[0-9a-f]{12}$`)
+
+// isPseudoVersion reports whether v is a pseudo-version like v0.0.0-20191109021931-daa7c04131f5.
+func isPseudoVersion(v string) bool {
+	return strings.Count(v, "-") >= 2 && semver.IsValid(v) && pseudoVersionRE.MatchString(v)
+}
+
+// pseudoVersionRev returns the revision of the pseudo-version v.
+func pseudoVersionRev(v string) string {
+	v = strings.TrimSuffix(v, semver.Build(v))
+	return v[strings.LastIndex(v, "-")+1:]
+}
+
+// pseudoVersionTime returns the timestamp of the pseudo-version v.
+func pseudoVersionTime(v string) string {
+	v = strings.TrimSuffix(v, semver.Build(v))
+	v = v[:strings.LastIndex(v, "-")]
+	i := strings.LastIndexAny(v, "-.")
+	return v[i+1:]
+}
@@ -0,0 +1,501 @@
+package gopp
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/module"
+)
+
+func gitRun(t *testing.T, dir string, date time.Time, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=gopp", "-c", "user.email=gopp@example.com"}, args...)...)
+	cmd.Dir = dir
+	d := date.Format(time.RFC3339)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+d, "GIT_COMMITTER_DATE="+d)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var (
+	directV010Time = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	directV020Time = time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)
+	directHeadTime = time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// makeTestRepo makes bare git repository of example.com/foo and returns
+// its path and the commit hash of HEAD which is not tagged.
+func makeTestRepo(t *testing.T, root string) (bare, head string) {
+	t.Helper()
+	work := filepath.Join(root, "work")
+	if err := os.MkdirAll(work, 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, work, directV010Time, "init", "--quiet")
+	writeFiles(t, work, map[string]string{
+		"go.mod": "module example.com/foo\n",
+		"foo.go": "package foo\n",
+	})
+	gitRun(t, work, directV010Time, "add", "-A")
+	gitRun(t, work, directV010Time, "commit", "--quiet", "-m", "v0.1.0")
+	gitRun(t, work, directV010Time, "tag", "v0.1.0")
+
+	writeFiles(t, work, map[string]string{
+		"foo.go":        "package foo\n\nconst V = 2\n",
+		"sub/go.mod":    "module example.com/foo/sub\n",
+		"sub/sub.go":    "package sub\n",
+		"vendor/x/x.go": "package x\n",
+	})
+	gitRun(t, work, directV020Time, "add", "-A")
+	gitRun(t, work, directV020Time, "commit", "--quiet", "-m", "v0.2.0")
+	gitRun(t, work, directV020Time, "tag", "v0.2.0")
+	gitRun(t, work, directV020Time, "tag", "sub/v1.0.0")
+	gitRun(t, work, directV020Time, "tag", "not-a-version")
+
+	writeFiles(t, work, map[string]string{"foo.go": "package foo\n\nconst V = 3\n"})
+	gitRun(t, work, directHeadTime, "add", "-A")
+	gitRun(t, work, directHeadTime, "commit", "--quiet", "-m", "head")
+	head = gitRun(t, work, directHeadTime, "rev-parse", "HEAD")
+	// v2.0.0 does not match the module path without /v2.
+	gitRun(t, work, directHeadTime, "tag", "v2.0.0")
+	// branches which look like the revision of pseudo-versions.
+	gitRun(t, work, directHeadTime, "branch", "feature")
+	gitRun(t, work, directHeadTime, "branch", "0123456789ab", "v0.1.0")
+
+	bare = filepath.Join(root, "foo.git")
+	gitRun(t, root, directHeadTime, "clone", "--quiet", "--bare", work, bare)
+	return bare, head
+}
+
+// newTestDirectClient makes DirectClient which resolves go-import meta tags
+// by the test server. file:// is allowed to fetch the local bare repository.
+func newTestDirectClient(t *testing.T, root, bare string) (*DirectClient, func()) {
+	t.Helper()
+	dc, closeSrv := newMetaTestDirectClient(t, root, bareURL(bare))
+	dc.schemes = []string{"file"}
+	return dc, closeSrv
+}
+
+func bareURL(bare string) string {
+	return "file://" + filepath.ToSlash(bare)
+}
+
+// newMetaTestDirectClient makes DirectClient which go-import meta tags for
+// example.com/foo point at repoURL.
+func newMetaTestDirectClient(t *testing.T, root, repoURL string) (*DirectClient, func()) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("go-get") != "1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `<!DOCTYPE html>
+<html><head>
+<meta name="go-import" content="example.com/foo git %s">
+<meta name="go-import" content="example.com/foo mod https://proxy.example.com">
+</head><body>go get example.com/foo</body></html>`, repoURL)
+	}))
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			req.URL.Scheme = srvURL.Scheme
+			req.URL.Host = srvURL.Host
+			return http.DefaultClient.Do(req)
+		},
+	}
+	dc, err := NewDirectClient(c, filepath.Join(root, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dc, srv.Close
+}
+
+func directGet(t *testing.T, dc *DirectClient, urlPath string) (int, []byte) {
+	t.Helper()
+	req := httptest.NewRequest("GET", urlPath, nil)
+	resp, err := dc.Do(req)
+	if err != nil {
+		t.Fatalf("DirectClient.Do(%s): %v", urlPath, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestDirectClient(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not found")
+	}
+	root, cleanup := tempDir(t)
+	defer cleanup()
+	bare, head := makeTestRepo(t, root)
+	dc, closeSrv := newTestDirectClient(t, root, bare)
+	defer closeSrv()
+
+	pseudo := "v0.0.0-" + directHeadTime.Format(pseudoVersionTimestampFormat) + "-" + head[:12]
+	upperPseudo, err := module.EscapeVersion(strings.TrimSuffix(pseudo, head[:12]) + strings.ToUpper(head[:12]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+		wantInfo *Info
+	}{
+		{
+			name:     "List",
+			urlPath:  "/example.com/foo/@v/list",
+			wantCode: http.StatusOK,
+			wantBody: "v0.1.0\nv0.2.0\n",
+		},
+		{
+			name:     "List of the module in subdirectory",
+			urlPath:  "/example.com/foo/sub/@v/list",
+			wantCode: http.StatusOK,
+			wantBody: "v1.0.0\n",
+		},
+		{
+			name:     "Latest",
+			urlPath:  "/example.com/foo/@latest",
+			wantCode: http.StatusOK,
+			wantInfo: &Info{Version: "v0.2.0", Time: directV020Time},
+		},
+		{
+			name:     "Info",
+			urlPath:  "/example.com/foo/@v/v0.1.0.info",
+			wantCode: http.StatusOK,
+			wantInfo: &Info{Version: "v0.1.0", Time: directV010Time},
+		},
+		{
+			name:     "Info of pseudo-version",
+			urlPath:  "/example.com/foo/@v/" + pseudo + ".info",
+			wantCode: http.StatusOK,
+			wantInfo: &Info{Version: pseudo, Time: directHeadTime},
+		},
+		{
+			name:     "Mod",
+			urlPath:  "/example.com/foo/@v/v0.1.0.mod",
+			wantCode: http.StatusOK,
+			wantBody: "module example.com/foo\n",
+		},
+		{
+			name:     "Mod of the module in subdirectory",
+			urlPath:  "/example.com/foo/sub/@v/v1.0.0.mod",
+			wantCode: http.StatusOK,
+			wantBody: "module example.com/foo/sub\n",
+		},
+		{
+			name:     "Unknown version",
+			urlPath:  "/example.com/foo/@v/v0.3.0.info",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Pseudo-version with wrong timestamp",
+			urlPath:  "/example.com/foo/@v/v0.0.0-20000101000000-" + head[:12] + ".info",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Info of mismatched major version",
+			urlPath:  "/example.com/foo/@v/v2.0.0.info",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Mod of mismatched major version",
+			urlPath:  "/example.com/foo/@v/v2.0.0.mod",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Zip of mismatched major version",
+			urlPath:  "/example.com/foo/@v/v2.0.0.zip",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Pseudo-version of branch",
+			urlPath:  "/example.com/foo/@v/v0.0.0-" + directHeadTime.Format(pseudoVersionTimestampFormat) + "-feature.info",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Pseudo-version of uppercase revision",
+			urlPath:  "/example.com/foo/@v/" + upperPseudo + ".zip",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Pseudo-version of branch named like commit hash",
+			urlPath:  "/example.com/foo/@v/v0.0.0-" + directV010Time.Format(pseudoVersionTimestampFormat) + "-0123456789ab.info",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Ziphash is not supported",
+			urlPath:  "/example.com/foo/@v/v0.1.0.ziphash",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Unknown module",
+			urlPath:  "/example.org/bar/@v/list",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := directGet(t, dc, tt.urlPath)
+			if code != tt.wantCode {
+				t.Fatalf("expected %d but got %d: %s", tt.wantCode, code, body)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("expected %q but got %q", tt.wantBody, string(body))
+			}
+			if tt.wantInfo != nil {
+				var info Info
+				if err := json.Unmarshal(body, &info); err != nil {
+					t.Fatal(err)
+				}
+				if info.Version != tt.wantInfo.Version || !info.Time.Equal(tt.wantInfo.Time) {
+					t.Errorf("expected %+v but got %+v", tt.wantInfo, info)
+				}
+				if info.Origin == nil || info.Origin.VCS != "git" || info.Origin.URL != bareURL(bare) || info.Origin.Hash == "" {
+					t.Errorf("unexpected origin: %+v", info.Origin)
+				}
+			}
+		})
+	}
+
+	t.Run("Zip", func(t *testing.T) {
+		code, body := directGet(t, dc, "/example.com/foo/@v/v0.2.0.zip")
+		if code != http.StatusOK {
+			t.Fatalf("expected %d but got %d: %s", http.StatusOK, code, body)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+		sort.Strings(names)
+		want := []string{
+			"example.com/foo@v0.2.0/foo.go",
+			"example.com/foo@v0.2.0/go.mod",
+		}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("expected %v but got %v", want, names)
+		}
+	})
+}
+
+func TestDirectClient_zipLineEndings(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not found")
+	}
+	root, cleanup := tempDir(t)
+	defer cleanup()
+	bare, _ := makeTestRepo(t, root)
+	dc, closeSrv := newTestDirectClient(t, root, bare)
+	defer closeSrv()
+
+	// the git config of the host converts line endings of archives.
+	gitConfig := filepath.Join(root, "gitconfig")
+	if err := ioutil.WriteFile(gitConfig, []byte("[core]\n\tautocrlf = true\n\teol = crlf\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old, ok := os.LookupEnv("GIT_CONFIG_GLOBAL")
+	os.Setenv("GIT_CONFIG_GLOBAL", gitConfig)
+	defer func() {
+		if ok {
+			os.Setenv("GIT_CONFIG_GLOBAL", old)
+		} else {
+			os.Unsetenv("GIT_CONFIG_GLOBAL")
+		}
+	}()
+
+	code, body := directGet(t, dc, "/example.com/foo/@v/v0.2.0.zip")
+	if code != http.StatusOK {
+		t.Fatalf("expected %d but got %d: %s", http.StatusOK, code, body)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte("\r\n")) {
+			t.Errorf("%s: line endings are converted: %q", f.Name, data)
+		}
+	}
+}
+
+func TestProxy_direct(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not found")
+	}
+	root, cleanup := tempDir(t)
+	defer cleanup()
+	bare, _ := makeTestRepo(t, root)
+	dc, closeSrv := newTestDirectClient(t, root, bare)
+	defer closeSrv()
+
+	c := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       ioutil.NopCloser(strings.NewReader("not found")),
+			}, nil
+		},
+	}
+	p, err := NewProxy(c, "https://proxy.example.com,direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 404 of the proxy is relayed until the direct client is added.
+	if rec := serve(p, "/example.com/foo/@v/list"); rec.Code != http.StatusNotFound || rec.Body.String() != "not found" {
+		t.Errorf("expected %d %q but got %d %q", http.StatusNotFound, "not found", rec.Code, rec.Body.String())
+	}
+	if err := p.AddDirectClient(dc); err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(p, "/example.com/foo/@v/list")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if want := "v0.1.0\nv0.2.0\n"; rec.Body.String() != want {
+		t.Errorf("expected %q but got %q", want, rec.Body.String())
+	}
+}
+
+func TestParseMetaGoImports(t *testing.T) {
+	html := `<html><head>
+<meta name="go-import" content="golang.org/x/net git https://go.googlesource.com/net">
+<meta name="go-source" content="golang.org/x/net https://github.com/golang/net/">
+</head><body><meta name="go-import" content="ignored git https://example.com"></body></html>`
+	got, err := parseMetaGoImports(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []metaImport{{prefix: "golang.org/x/net", vcs: "git", repoURL: "https://go.googlesource.com/net"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v but got %v", want, got)
+	}
+}
+
+func TestIsPseudoVersion(t *testing.T) {
+	tests := []struct {
+		v    string
+		want bool
+	}{
+		{v: "v0.0.0-20191109021931-daa7c04131f5", want: true},
+		{v: "v1.2.4-0.20191109021931-daa7c04131f5", want: true},
+		{v: "v1.2.4-pre.0.20191109021931-daa7c04131f5", want: true},
+		{v: "v2.0.0-20191109021931-daa7c04131f5+incompatible", want: true},
+		{v: "v1.2.3", want: false},
+		{v: "v1.2.3-pre", want: false},
+		{v: "v1.2.3-20191109021931-daa7c04131f5", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.v, func(t *testing.T) {
+			if got := isPseudoVersion(tt.v); got != tt.want {
+				t.Errorf("expected %v but got %v", tt.want, got)
+			}
+		})
+	}
+	if got, want := pseudoVersionRev("v1.2.4-0.20191109021931-daa7c04131f5"), "daa7c04131f5"; got != want {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+	if got, want := pseudoVersionTime("v1.2.4-0.20191109021931-daa7c04131f5"), "20191109021931"; got != want {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+}
+
+func TestDirectClient_invalidRepoURL(t *testing.T) {
+	root, cleanup := tempDir(t)
+	defer cleanup()
+	bare, _ := makeTestRepo(t, root)
+	pwned := filepath.Join(root, "pwned")
+
+	tests := []struct {
+		name    string
+		repoURL string
+		schemes []string // allowed schemes. defaultRepoSchemes when it is nil
+	}{
+		// file:// is allowed so that GIT_ALLOW_PROTOCOL does not hide the injection.
+		{name: "Option injection", repoURL: "--upload-pack=touch${IFS}" + pwned + ";", schemes: []string{"file"}},
+		{name: "Option injection with scheme", repoURL: "-file://" + filepath.ToSlash(bare), schemes: []string{"file"}},
+		{name: "Ext transport", repoURL: "ext::sh${IFS}-c${IFS}touch${IFS}" + pwned, schemes: []string{"file"}},
+		{name: "Local path", repoURL: bare, schemes: []string{"file"}},
+		{name: "File scheme", repoURL: bareURL(bare)},
+		{name: "Unsupported scheme", repoURL: "http://example.com/foo"},
+		{name: "Dash host", repoURL: "ssh://-oProxyCommand=touch${IFS}" + pwned + "/foo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dc, closeSrv := newMetaTestDirectClient(t, root, tt.repoURL)
+			defer closeSrv()
+			if tt.schemes != nil {
+				dc.schemes = tt.schemes
+			}
+			code, body := directGet(t, dc, "/example.com/foo/@v/list")
+			if code != http.StatusNotFound {
+				t.Errorf("expected %d but got %d: %s", http.StatusNotFound, code, body)
+			}
+			if !strings.Contains(string(body), "invalid repository url") {
+				t.Errorf("unexpected body: %s", body)
+			}
+			if _, err := os.Stat(pwned); !os.IsNotExist(err) {
+				t.Fatalf("git executed the injected command: %v", err)
+			}
+		})
+	}
+}
+
+func TestDirectClient_allowProtocol(t *testing.T) {
+	dc := &DirectClient{schemes: defaultRepoSchemes}
+	if got, want := dc.allowProtocol(), "https:ssh"; got != want {
+		t.Errorf("expected %q but got %q", want, got)
+	}
+}
@@ -2,12 +2,62 @@ package gopp
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 )
 
+// maxUpstreamErrorBody is limit size of the upstream body which is kept in UpstreamStatusError.
+const maxUpstreamErrorBody = 64 << 10
+
 // ErrHandler represents handler for handling error
 type ErrHandler func(w http.ResponseWriter, r *http.Request, err error)
 
+// UpstreamStatusError represents non-200 response of the upstream GOPROXY.
+// The go command treats 404 and 410 as "not found" and tries the next proxy,
+// so this status should be relayed to the client as it is.
+type UpstreamStatusError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %s", e.Status)
+}
+
+// NotFound reports whether the upstream answered 404 or 410.
+func (e *UpstreamStatusError) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+}
+
+func newUpstreamStatusError(resp *http.Response) error {
+	status := resp.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxUpstreamErrorBody))
+	return &UpstreamStatusError{
+		StatusCode: resp.StatusCode,
+		Status:     status,
+		Body:       body,
+	}
+}
+
+// statusError is an error which has HTTP status code to respond.
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+
+func (e *statusError) Unwrap() error { return e.err }
+
+// StatusCode returns HTTP status code to respond.
+func (e *statusError) StatusCode() int { return e.code }
+
 // AddErrHandler registers error handler
 func (p *Proxy) AddErrHandler(h ErrHandler) error {
 	if h == nil {
@@ -19,6 +69,32 @@ func (p *Proxy) AddErrHandler(h ErrHandler) error {
 
 func defaultErrHandler() ErrHandler {
 	return func(w http.ResponseWriter, r *http.Request, err error) {
+		var se *UpstreamStatusError
+		if errors.As(err, &se) {
+			writeUpstreamStatusError(w, se)
+			return
+		}
+		var sc interface{ StatusCode() int }
+		if errors.As(err, &sc) {
+			http.Error(w, err.Error(), sc.StatusCode())
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+func writeUpstreamStatusError(w http.ResponseWriter, se *UpstreamStatusError) {
+	code := se.StatusCode
+	if code < http.StatusBadRequest {
+		// upstream answered something which is neither 200 nor error.
+		code = http.StatusBadGateway
+	}
+	if len(se.Body) == 0 {
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(se.Body)
+}
@@ -1,7 +1,10 @@
 package gopp
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -32,3 +35,59 @@ func TestProxy_AddErrHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultErrHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Unknown error",
+			err:      errors.New("error"),
+			wantCode: http.StatusInternalServerError,
+			wantBody: "error\n",
+		},
+		{
+			name: "Upstream 404",
+			err: &UpstreamStatusError{
+				StatusCode: http.StatusNotFound,
+				Status:     "404 Not Found",
+				Body:       []byte("not found: unknown revision v0.0.1"),
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: "not found: unknown revision v0.0.1",
+		},
+		{
+			name: "Wrapped upstream 410",
+			err: fmt.Errorf("wrapped: %w", &UpstreamStatusError{
+				StatusCode: http.StatusGone,
+				Status:     "410 Gone",
+			}),
+			wantCode: http.StatusGone,
+			wantBody: "Gone\n",
+		},
+		{
+			name: "Upstream redirect",
+			err: &UpstreamStatusError{
+				StatusCode: http.StatusFound,
+				Status:     "302 Found",
+			},
+			wantCode: http.StatusBadGateway,
+			wantBody: "Bad Gateway\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			defaultErrHandler()(rec, httptest.NewRequest("GET", "/", nil), tt.err)
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected %d but got %d", tt.wantCode, rec.Code)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("expected %q but got %q", tt.wantBody, got)
+			}
+		})
+	}
+}
@@ -1,5 +1,5 @@
 module github.com/Code-Hex/gopp
 
-go 1.12
+go 1.13
 
-require golang.org/x/mod v0.0.0-20190513183733-4bf6d317e70e
+require golang.org/x/mod v0.4.2
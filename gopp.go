@@ -1,35 +1,69 @@
 package gopp
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
-	"path"
-	"path/filepath"
-	"strings"
 	"time"
-
-	"golang.org/x/mod/semver"
 )
 
 // ProxyClient interface represents http client.
+// The context of the request is derived from the incoming request, so the
+// client should abort the request when it is canceled.
 // http.Client is satisfied this interface.
 type ProxyClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
 // Info defined for json response. see `go help goproxy`
+// Unknown fields of the upstream response are kept in Extra and encoded again,
+// so that they are passed through to the client.
 type Info struct {
 	Version string    // version string
 	Time    time.Time // commit time
+	Origin  *Origin   `json:",omitempty"` // provenance of the version. nil when it is unknown
+
+	Extra map[string]json.RawMessage `json:"-"` // unknown fields
+}
+
+// Origin describes the provenance of the version which is sent by newer go
+// commands and proxies.
+type Origin struct {
+	VCS       string `json:",omitempty"` // "git" etc
+	URL       string `json:",omitempty"` // repository URL
+	Subdir    string `json:",omitempty"` // subdirectory of the module in the repository
+	Hash      string `json:",omitempty"` // commit hash
+	TagPrefix string `json:",omitempty"` // prefix of the tags of the module
+	TagSum    string `json:",omitempty"` // checksum of the tags
+	Ref       string `json:",omitempty"` // ref like "refs/tags/v1.0.0"
+	RepoSum   string `json:",omitempty"` // checksum of the repository
+
+	Extra map[string]json.RawMessage `json:"-"` // unknown fields
 }
 
 // Proxy proxies to GOPROXY of upstream.
 // this struct is satisfied http.Handler.
 type Proxy struct {
-	u      *url.URL
-	client ProxyClient
+	upstreams   []*upstream
+	client      ProxyClient
+	routes      []*route
+	direct      ProxyClient
+	cache       *Cache
+	timeouts    map[Kind]time.Duration
+	verifier    *Verifier
+	policy      *Policy
+	latest      LatestMode
+	validations map[Kind]bool
+	zipHash     bool
+	accessLog   *accessLog
+	metrics     *Metrics
+	probeModule string // health check is enabled when it is not empty
+	coalescer   *coalescer
+	sumdbs      map[string]*url.URL
 
 	errHandler ErrHandler
 
@@ -37,73 +71,240 @@ type Proxy struct {
 	versionZipHandler  ZipProxyHandler
 	versionModHandler  ModProxyHandler
 	versionListHandler ListProxyHandler
+	listTransformers   []ListTransformer
 }
 
 // NewProxy makes proxy of the GOPROXY. returns Proxy struct which is satisfied http.Handler.
+// upstreamGoProxyHost accepts the same syntax as GOPROXY environment variable
+// such as "https://a,https://b|file:///c,direct,off". "direct" is treated as
+// not found until AddDirectClient is called.
+// The returned proxy passes every response through as it is until handlers are
+// replaced by AddXxxProxyHandler.
 func NewProxy(c ProxyClient, upstreamGoProxyHost string) (*Proxy, error) {
-	// we expected `upstreamGoProxyHost == "https://original-goproxy.host"`
-	u, err := url.ParseRequestURI(upstreamGoProxyHost)
+	upstreams, err := parseGoProxy(upstreamGoProxyHost)
 	if err != nil {
 		return nil, fmt.Errorf("unexpected host: %v", err)
 	}
 	return &Proxy{
-		u:      u,
-		client: c,
+		upstreams:          upstreams,
+		client:             c,
+		errHandler:         defaultErrHandler(),
+		versionInfoHandler: defaultInfoProxyHandler(),
+		versionZipHandler:  defaultZipProxyHandler(),
+		versionModHandler:  defaultModProxyHandler(),
+		versionListHandler: defaultListProxyHandler(),
 	}, nil
 }
 
+// Upstream returns the upstream GOPROXY list of NewProxy in which passwords
+// of the urls are redacted, so that it can be logged.
+func (p *Proxy) Upstream() string {
+	return formatGoProxy(p.upstreams)
+}
+
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p.makeHandler().ServeHTTP(w, r)
 }
 
-func (p *Proxy) request(path string) (*http.Response, error) {
-	u := *p.u // clone
-	u.Path = path
-	req, err := http.NewRequest("GET", u.String(), nil)
+// fetch returns the body of mr from the cache or the upstream GOPROXY.
+func (p *Proxy) fetch(ctx context.Context, mr *ModuleRequest) (io.ReadCloser, error) {
+	if p.cache != nil {
+		body, ok := p.cache.get(ctx, mr)
+		p.recordCache(ctx, mr, ok)
+		if ok {
+			return body, nil
+		}
+	}
+	resp, err := p.requestModule(ctx, mr)
 	if err != nil {
 		return nil, err
 	}
-	return p.client.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newUpstreamStatusError(resp)
+	}
+	body := resp.Body
+	if p.validations[mr.Kind] {
+		body, err = p.validate(mr, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.verifier != nil && (mr.Kind == KindMod || mr.Kind == KindZip) {
+		body, err = p.verifier.verify(mr, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.cache != nil {
+		return p.cache.tee(ctx, mr, body), nil
+	}
+	return body, nil
+}
+
+// requestModule requests mr to the upstream GOPROXY.
+func (p *Proxy) requestModule(ctx context.Context, mr *ModuleRequest) (*http.Response, error) {
+	urlPath, err := mr.URLPath()
+	if err != nil {
+		return nil, err
+	}
+	return p.request(ctx, mr.Path, urlPath)
+}
+
+// request requests path to the upstream GOPROXY. Concurrent requests for the
+// same path are coalesced when EnableCoalescing is called.
+func (p *Proxy) request(ctx context.Context, modPath, path string) (*http.Response, error) {
+	if p.coalescer != nil {
+		return p.coalescer.do(ctx, path, func(ctx context.Context) (*http.Response, error) {
+			return p.walkUpstreams(ctx, modPath, path)
+		})
+	}
+	return p.walkUpstreams(ctx, modPath, path)
+}
+
+// walkUpstreams walks the upstream GOPROXY list of the route for modPath in the
+// same way as the go command.
+// Non-200 response is returned when no more upstream can be tried.
+func (p *Proxy) walkUpstreams(ctx context.Context, modPath, path string) (*http.Response, error) {
+	client, upstreams := p.routeFor(modPath)
+	// the response or the error of the last upstream which fell through.
+	var (
+		lastResp *http.Response
+		lastErr  error
+	)
+	for i, up := range upstreams {
+		last := i == len(upstreams)-1
+		if up.direct && p.direct == nil {
+			// direct is treated as not found when it is not configured, so
+			// that "https://proxy.golang.org,direct" relays 404 of the proxy.
+			if !last {
+				continue
+			}
+			break
+		}
+		start := time.Now()
+		resp, err := p.requestUpstream(ctx, client, up, path)
+		p.recordUpstream(ctx, up, resp, err, time.Since(start))
+		if lastResp != nil {
+			lastResp.Body.Close()
+		}
+		lastResp, lastErr = nil, nil
+		if err != nil {
+			if last || !up.fallBackOnError {
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode == http.StatusOK || last {
+			return resp, nil
+		}
+		notFound := resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone
+		if !notFound && !up.fallBackOnError {
+			return resp, nil
+		}
+		lastResp = resp
+	}
+	switch {
+	case lastResp != nil:
+		return lastResp, nil
+	case lastErr != nil:
+		return nil, lastErr
+	}
+	return textResponse(http.StatusNotFound, "direct mode is not configured"), nil
+}
+
+func (p *Proxy) requestUpstream(ctx context.Context, client ProxyClient, up *upstream, path string) (*http.Response, error) {
+	switch {
+	case up.direct:
+		if p.direct == nil {
+			return nil, errDirectNotSupported
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+		return p.direct.Do(req)
+	case up.off:
+		return nil, errGoProxyOff
+	case up.client != nil:
+		req, err := http.NewRequestWithContext(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+		return up.client.Do(req)
+	}
+	u := joinURLPath(up.u, path)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
 }
 
 func (p *Proxy) makeHandler() http.Handler {
 	if p.errHandler == nil {
 		p.errHandler = defaultErrHandler()
 	}
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if err := p.handlers(w, r); err != nil {
 			p.errHandler(w, r, err)
 		}
 	})
+	if p.accessLog == nil && p.metrics == nil {
+		return h
+	}
+	return p.observe(h)
 }
 
 func (p *Proxy) handlers(w http.ResponseWriter, r *http.Request) error {
-	urlPath := r.URL.Path
-	switch {
-	case strings.HasSuffix(urlPath, "/@latest"):
-		return p.versionInfoProxy(w, r)
-	case strings.HasSuffix(urlPath, "/@v/list"):
-		return p.versionListProxy(w, r)
-	default:
-		basename := path.Base(urlPath)
-		fileExt := filepath.Ext(basename)
-		version := strings.TrimSuffix(basename, fileExt)
-		// expected semantic version format like v1.0.0
-		if !semver.IsValid(version) {
-			return fmt.Errorf("unexpected semantic version format: %s", version)
+	if isSumDBPath(r.URL.Path) {
+		return p.sumdbProxy(w, r)
+	}
+	if p.probeModule != "" {
+		switch r.URL.Path {
+		case healthzPath:
+			return p.healthz(w, r)
+		case readyzPath:
+			return p.readyz(w, r)
 		}
-		// expected path like /@v/v0.0.1.info
-		if !strings.HasSuffix(urlPath, "/@v/"+basename) {
-			return fmt.Errorf("unexpected module path: %s", urlPath)
+	}
+	if p.metrics != nil && r.URL.Path == metricsPath {
+		p.metrics.ServeHTTP(w, r)
+		return nil
+	}
+	mr, err := parseModuleRequest(r.URL.Path)
+	if err != nil {
+		return err
+	}
+	r = withModuleRequest(r, mr)
+	if p.policy != nil {
+		if err := p.policy.check(mr); err != nil {
+			return err
 		}
-		switch fileExt {
-		case ".info":
-			return p.versionInfoProxy(w, r)
-		case ".zip":
-			return p.versionZipProxy(w, r)
-		case ".mod":
-			return p.versionModProxy(w, r)
+	}
+	timeoutKind := mr.Kind
+	if timeoutKind == KindZipHash {
+		timeoutKind = KindZip
+	}
+	if d, ok := p.timeouts[timeoutKind]; ok {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	switch mr.Kind {
+	case KindLatest, KindInfo:
+		return p.versionInfoProxy(w, r, mr)
+	case KindList:
+		return p.versionListProxy(w, r, mr)
+	case KindZip:
+		return p.versionZipProxy(w, r, mr)
+	case KindMod:
+		return p.versionModProxy(w, r, mr)
+	case KindZipHash:
+		if p.zipHash {
+			return p.zipHashProxy(w, r, mr)
 		}
 	}
-	return errors.New("unexpected url path")
+	return &statusError{code: http.StatusNotFound, err: errors.New("unexpected url path")}
 }
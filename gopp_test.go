@@ -1,12 +1,14 @@
 package gopp
 
 import (
+	"context"
 	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -41,7 +43,7 @@ var (
 			),
 		)
 	}
-	emptyBody = ioutil.NopCloser(nil)
+	emptyBody = ioutil.NopCloser(strings.NewReader(""))
 )
 
 func TestNewProxy(t *testing.T) {
@@ -55,6 +57,11 @@ func TestNewProxy(t *testing.T) {
 			upstream: "https://localhost",
 			wantErr:  false,
 		},
+		{
+			name:     "Valid list",
+			upstream: "https://localhost,https://proxy.golang.org|direct",
+			wantErr:  false,
+		},
 		{
 			name:     "Invalid",
 			upstream: "",
@@ -71,6 +78,73 @@ func TestNewProxy(t *testing.T) {
 	}
 }
 
+func TestNewProxy_defaultHandlers(t *testing.T) {
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     func() io.ReadCloser
+		wantBody string
+	}{
+		{
+			name:     "/@latest",
+			urlPath:  "/github.com/pkg/errors/@latest",
+			body:     versionJSON,
+			wantBody: `{"Version":"v0.0.1","Time":"2019-01-02T22:52:24-08:00"}` + "\n",
+		},
+		{
+			name:     "/@v/list",
+			urlPath:  "/github.com/pkg/errors/@v/list",
+			body:     versionList,
+			wantBody: "v0.0.1\nv0.0.2\n",
+		},
+		{
+			name:     "/@v/v0.0.1.info",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.1.info",
+			body:     versionJSON,
+			wantBody: `{"Version":"v0.0.1","Time":"2019-01-02T22:52:24-08:00"}` + "\n",
+		},
+		{
+			name:     "/@v/v0.0.1.mod",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.1.mod",
+			body:     moduleFILE,
+			wantBody: "module github.com/pkg/errors",
+		},
+		{
+			name:    "/@v/v0.0.1.zip",
+			urlPath: "/github.com/pkg/errors/@v/v0.0.1.zip",
+			body: func() io.ReadCloser {
+				return ioutil.NopCloser(strings.NewReader("zip"))
+			},
+			wantBody: "zip",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &mockClient{
+				DoMock: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       tt.body(),
+					}, nil
+				},
+			}
+			p, err := NewProxy(c, "https://localhost")
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest("GET", tt.urlPath, nil)
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected %d but got %d", http.StatusOK, rec.Code)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("expected %q but got %q", tt.wantBody, got)
+			}
+		})
+	}
+}
+
 func TestProxy_handlers(t *testing.T) {
 	type fields struct {
 		client             ProxyClient
@@ -471,7 +545,7 @@ func TestProxy_handlers(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := &Proxy{
-				u:                  &url.URL{},
+				upstreams:          []*upstream{{u: &url.URL{}}},
 				client:             tt.fields.client,
 				versionInfoHandler: tt.fields.versionInfoHandler,
 				versionZipHandler:  tt.fields.versionZipHandler,
@@ -542,11 +616,26 @@ func TestProxy_ServeHTTP(t *testing.T) {
 			urlPath:  "https://localhost/github.com/pkg/errors/@latest~~~",
 			wantCode: http.StatusInternalServerError,
 		},
+		{
+			name: "Upstream not found",
+			fields: fields{
+				client: &mockClient{
+					DoMock: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{
+							StatusCode: http.StatusNotFound,
+							Body:       ioutil.NopCloser(strings.NewReader("not found")),
+						}, nil
+					},
+				},
+			},
+			urlPath:  "https://localhost/github.com/pkg/errors/@v/v0.0.1.zip",
+			wantCode: http.StatusNotFound,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := &Proxy{
-				u:                  &url.URL{},
+				upstreams:          []*upstream{{u: &url.URL{}}},
 				client:             tt.fields.client,
 				versionInfoHandler: tt.fields.versionInfoHandler,
 			}
@@ -560,14 +649,151 @@ func TestProxy_ServeHTTP(t *testing.T) {
 	}
 }
 
+func TestProxy_request(t *testing.T) {
+	tests := []struct {
+		name      string
+		goproxy   string
+		responses map[string]int // host -> status code. missing host fails to connect.
+		wantHosts []string
+		wantCode  int
+		wantErr   bool
+	}{
+		{
+			name:      "First",
+			goproxy:   "https://a,https://b",
+			responses: map[string]int{"a": http.StatusOK, "b": http.StatusOK},
+			wantHosts: []string{"a"},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "Comma falls back on 404",
+			goproxy:   "https://a,https://b",
+			responses: map[string]int{"a": http.StatusNotFound, "b": http.StatusOK},
+			wantHosts: []string{"a", "b"},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "Comma falls back on 410",
+			goproxy:   "https://a,https://b",
+			responses: map[string]int{"a": http.StatusGone, "b": http.StatusNotFound},
+			wantHosts: []string{"a", "b"},
+			wantCode:  http.StatusNotFound,
+		},
+		{
+			name:      "Comma stops on 500",
+			goproxy:   "https://a,https://b",
+			responses: map[string]int{"a": http.StatusInternalServerError, "b": http.StatusOK},
+			wantHosts: []string{"a"},
+			wantCode:  http.StatusInternalServerError,
+		},
+		{
+			name:      "Comma stops on connection error",
+			goproxy:   "https://a,https://b",
+			responses: map[string]int{"b": http.StatusOK},
+			wantHosts: []string{"a"},
+			wantErr:   true,
+		},
+		{
+			name:      "Pipe falls back on 500",
+			goproxy:   "https://a|https://b",
+			responses: map[string]int{"a": http.StatusInternalServerError, "b": http.StatusOK},
+			wantHosts: []string{"a", "b"},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "Pipe falls back on connection error",
+			goproxy:   "https://a|https://b",
+			responses: map[string]int{"b": http.StatusOK},
+			wantHosts: []string{"a", "b"},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "Unconfigured direct relays 404",
+			goproxy:   "https://a,direct",
+			responses: map[string]int{"a": http.StatusNotFound},
+			wantHosts: []string{"a"},
+			wantCode:  http.StatusNotFound,
+		},
+		{
+			name:      "Unconfigured direct is skipped",
+			goproxy:   "https://a,direct,https://b",
+			responses: map[string]int{"a": http.StatusNotFound, "b": http.StatusOK},
+			wantHosts: []string{"a", "b"},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "Unconfigured direct after error",
+			goproxy:   "https://a|direct",
+			responses: map[string]int{},
+			wantHosts: []string{"a"},
+			wantErr:   true,
+		},
+		{
+			name:      "Unconfigured direct after 500",
+			goproxy:   "https://a|direct",
+			responses: map[string]int{"a": http.StatusInternalServerError},
+			wantHosts: []string{"a"},
+			wantCode:  http.StatusInternalServerError,
+		},
+		{
+			name:      "Unconfigured direct only",
+			goproxy:   "direct",
+			responses: map[string]int{},
+			wantCode:  http.StatusNotFound,
+		},
+		{
+			name:      "Off",
+			goproxy:   "https://a,off",
+			responses: map[string]int{"a": http.StatusNotFound},
+			wantHosts: []string{"a"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotHosts []string
+			c := &mockClient{
+				DoMock: func(req *http.Request) (*http.Response, error) {
+					gotHosts = append(gotHosts, req.URL.Host)
+					code, ok := tt.responses[req.URL.Host]
+					if !ok {
+						return nil, errors.New("connection refused")
+					}
+					return &http.Response{
+						StatusCode: code,
+						Body:       ioutil.NopCloser(strings.NewReader("")),
+					}, nil
+				},
+			}
+			p, err := NewProxy(c, tt.goproxy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := p.request(context.Background(), "github.com/pkg/errors", "/github.com/pkg/errors/@v/list")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Proxy.request() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(gotHosts, tt.wantHosts) {
+				t.Errorf("expected %v but got %v", tt.wantHosts, gotHosts)
+			}
+			if tt.wantErr {
+				return
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("expected %d but got %d", tt.wantCode, resp.StatusCode)
+			}
+		})
+	}
+}
+
 // for 100% coverage
 func TestProxy_requestErr(t *testing.T) {
 	p := &Proxy{
-		u: &url.URL{
-			Scheme: "unexpected",
+		upstreams: []*upstream{
+			{u: &url.URL{Scheme: "unexpected", Host: "[::1"}},
 		},
 	}
-	_, err := p.request("unexpected path")
+	_, err := p.request(context.Background(), "github.com/pkg/errors", "unexpected path")
 	if err == nil {
 		t.Errorf("unexpected err is nil")
 	}
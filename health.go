@@ -0,0 +1,135 @@
+package gopp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/module"
+)
+
+// Paths of the health check endpoints. They never collide with the GOPROXY
+// protocol because module requests always have "/@v/" or "/@latest".
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+)
+
+// readinessTimeout is the timeout of probing all upstreams.
+const readinessTimeout = 5 * time.Second
+
+// EnableHealthCheck enables /healthz for liveness and /readyz for readiness.
+// /readyz requests /@v/list of probeModule to every upstream of NewProxy and
+// AddRoute by the configured ProxyClient, and responds 503 when any of them
+// is not reachable. 404 and 410 are treated as reachable. "direct" and "off"
+// are not probed.
+func (p *Proxy) EnableHealthCheck(probeModule string) error {
+	if err := module.CheckPath(probeModule); err != nil {
+		return fmt.Errorf("unexpected probe module: %v", err)
+	}
+	p.probeModule = probeModule
+	return nil
+}
+
+// Readiness is the response of /readyz.
+type Readiness struct {
+	Ready     bool             `json:"ready"`
+	Upstreams []UpstreamStatus `json:"upstreams"`
+}
+
+// UpstreamStatus is the result of probing the upstream.
+type UpstreamStatus struct {
+	Upstream string `json:"upstream"`
+	Ready    bool   `json:"ready"`
+	Status   int    `json:"status,omitempty"` // status code of the upstream. 0 when the request is failed
+	Error    string `json:"error,omitempty"`
+}
+
+func (p *Proxy) healthz(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err := io.WriteString(w, "ok\n")
+	return err
+}
+
+func (p *Proxy) readyz(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	rd := p.probe(ctx)
+	w.Header().Set("Content-Type", "application/json")
+	if !rd.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	return json.NewEncoder(w).Encode(rd)
+}
+
+type probeTarget struct {
+	client ProxyClient
+	up     *upstream
+}
+
+// probe probes all upstreams concurrently.
+func (p *Proxy) probe(ctx context.Context) *Readiness {
+	var targets []probeTarget
+	for _, up := range p.upstreams {
+		targets = append(targets, probeTarget{client: p.client, up: up})
+	}
+	for _, rt := range p.routes {
+		for _, up := range rt.upstreams {
+			targets = append(targets, probeTarget{client: rt.client, up: up})
+		}
+	}
+	// the module path is checked by EnableHealthCheck.
+	escapedPath, _ := module.EscapePath(p.probeModule)
+	path := "/" + escapedPath + "/@v/list"
+
+	rd := &Readiness{Ready: true}
+	var wg sync.WaitGroup
+	results := make([]*UpstreamStatus, len(targets))
+	for i, target := range targets {
+		if target.up.direct || target.up.off {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, target probeTarget) {
+			defer wg.Done()
+			results[i] = p.probeUpstream(ctx, target.client, target.up, path)
+		}(i, target)
+	}
+	wg.Wait()
+	for _, st := range results {
+		if st == nil {
+			continue
+		}
+		if !st.Ready {
+			rd.Ready = false
+		}
+		rd.Upstreams = append(rd.Upstreams, *st)
+	}
+	return rd
+}
+
+func (p *Proxy) probeUpstream(ctx context.Context, client ProxyClient, up *upstream, path string) *UpstreamStatus {
+	st := &UpstreamStatus{Upstream: up.String()}
+	start := time.Now()
+	resp, err := p.requestUpstream(ctx, client, up, path)
+	p.recordUpstream(ctx, up, resp, err, time.Since(start))
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	st.Status = resp.StatusCode
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNotFound, http.StatusGone:
+		st.Ready = true
+	default:
+		st.Error = resp.Status
+	}
+	return st
+}
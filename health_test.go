@@ -0,0 +1,139 @@
+package gopp
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newHealthTestClient makes client which responds statuses by host. requested
+// urls are appended to requested, which is safe to read after the probe.
+func newHealthTestClient(statuses map[string]int, requested *[]string) *mockClient {
+	var mu sync.Mutex // upstreams are probed concurrently
+	return &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			*requested = append(*requested, req.URL.String())
+			mu.Unlock()
+			code, ok := statuses[req.URL.Host]
+			if !ok {
+				return nil, errors.New("connection refused")
+			}
+			return &http.Response{
+				StatusCode: code,
+				Status:     http.StatusText(code),
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+			}, nil
+		},
+	}
+}
+
+func TestProxy_healthz(t *testing.T) {
+	var requested []string
+	p, err := NewProxy(newHealthTestClient(nil, &requested), "https://a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve(p, "/healthz"); rec.Code == http.StatusOK {
+		t.Error("/healthz must not be served before EnableHealthCheck")
+	}
+	if err := p.EnableHealthCheck("golang.org/x/mod"); err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(p, "/healthz")
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok\n" {
+		t.Errorf("expected %d %q but got %d %q", http.StatusOK, "ok\n", rec.Code, rec.Body.String())
+	}
+	if len(requested) > 0 {
+		t.Errorf("/healthz must not request upstream: %v", requested)
+	}
+}
+
+func TestProxy_readyz(t *testing.T) {
+	tests := []struct {
+		name          string
+		goproxy       string
+		route         string
+		statuses      map[string]int
+		wantCode      int
+		wantReadiness Readiness
+	}{
+		{
+			name:     "Ready",
+			goproxy:  "https://a|https://b,direct,off",
+			route:    "https://c",
+			statuses: map[string]int{"a": http.StatusOK, "b": http.StatusNotFound, "c": http.StatusGone},
+			wantCode: http.StatusOK,
+			wantReadiness: Readiness{
+				Ready: true,
+				Upstreams: []UpstreamStatus{
+					{Upstream: "https://a", Ready: true, Status: http.StatusOK},
+					{Upstream: "https://b", Ready: true, Status: http.StatusNotFound},
+					{Upstream: "https://c", Ready: true, Status: http.StatusGone},
+				},
+			},
+		},
+		{
+			name:     "Not ready",
+			goproxy:  "https://a,https://b",
+			route:    "https://c",
+			statuses: map[string]int{"a": http.StatusOK, "b": http.StatusBadGateway},
+			wantCode: http.StatusServiceUnavailable,
+			wantReadiness: Readiness{
+				Ready: false,
+				Upstreams: []UpstreamStatus{
+					{Upstream: "https://a", Ready: true, Status: http.StatusOK},
+					{Upstream: "https://b", Ready: false, Status: http.StatusBadGateway, Error: "Bad Gateway"},
+					{Upstream: "https://c", Ready: false, Error: "connection refused"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			c := newHealthTestClient(tt.statuses, &requested)
+			p, err := NewProxy(c, tt.goproxy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddRoute("corp.example.com", c, tt.route); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.EnableHealthCheck("golang.org/x/mod"); err != nil {
+				t.Fatal(err)
+			}
+			rec := serve(p, "/readyz")
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected %d but got %d", tt.wantCode, rec.Code)
+			}
+			var got Readiness
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.wantReadiness) {
+				t.Errorf("expected %+v but got %+v", tt.wantReadiness, got)
+			}
+			for _, u := range requested {
+				if !strings.HasSuffix(u, "/golang.org/x/mod/@v/list") {
+					t.Errorf("unexpected probe: %s", u)
+				}
+			}
+		})
+	}
+}
+
+func TestProxy_EnableHealthCheck(t *testing.T) {
+	p := &Proxy{}
+	if err := p.EnableHealthCheck(""); err == nil {
+		t.Error("expected error for empty module")
+	}
+	if err := p.EnableHealthCheck("golang.org/x/mod/@v"); err == nil {
+		t.Error("expected error for invalid module")
+	}
+}
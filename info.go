@@ -1,11 +1,14 @@
 package gopp
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"sort"
+	"strings"
 )
 
 // InfoProxyHandler represents proxy handler for /@latest and /@v/v0.0.1.info
@@ -21,26 +24,51 @@ func (p *Proxy) AddInfoProxyHandler(h InfoProxyHandler) error {
 	return nil
 }
 
-func (p *Proxy) versionInfoProxy(w http.ResponseWriter, r *http.Request) error {
-	// /golang.org/x/net/latest
-	resp, err := p.request(r.URL.Path)
-	if err != nil {
-		return err
+func defaultInfoProxyHandler() InfoProxyHandler {
+	return func(w http.ResponseWriter, r *http.Request, info *Info) error {
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(info)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %s", resp.Status)
+}
+
+func (p *Proxy) versionInfoProxy(w http.ResponseWriter, r *http.Request, mr *ModuleRequest) error {
+	// /golang.org/x/net/@latest or /golang.org/x/net/@v/v0.0.1.info
+	var (
+		info *Info
+		err  error
+	)
+	if mr.Kind == KindLatest {
+		info, err = p.resolveLatest(r.Context(), mr)
+	} else {
+		info, err = p.fetchInfo(r.Context(), mr)
 	}
-	latest, err := body2VersionInfo(resp.Body)
 	if err != nil {
 		return err
 	}
-	if err := p.versionInfoHandler(w, r, latest); err != nil {
+	if err := p.versionInfoHandler(w, r, info); err != nil {
 		return err
 	}
 	return nil
 }
 
+// fetchInfo fetches /@latest or .info of mr and decodes it.
+func (p *Proxy) fetchInfo(ctx context.Context, mr *ModuleRequest) (*Info, error) {
+	body, err := p.fetch(ctx, mr)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	info, err := body2VersionInfo(body)
+	if err != nil {
+		return nil, err
+	}
+	// read until EOF so that the body can be committed to the cache.
+	if _, err := io.Copy(ioutil.Discard, body); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
 func body2VersionInfo(body io.Reader) (*Info, error) {
 	var info Info
 	if err := json.NewDecoder(body).Decode(&info); err != nil {
@@ -48,3 +76,112 @@ func body2VersionInfo(body io.Reader) (*Info, error) {
 	}
 	return &info, nil
 }
+
+var (
+	infoFields   = []string{"Version", "Time", "Origin"}
+	originFields = []string{"VCS", "URL", "Subdir", "Hash", "TagPrefix", "TagSum", "Ref", "RepoSum"}
+)
+
+// UnmarshalJSON implements json.Unmarshaler. Unknown fields are kept in Extra.
+func (info *Info) UnmarshalJSON(data []byte) error {
+	type plain Info // plain does not have UnmarshalJSON
+	var v plain
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, infoFields)
+	if err != nil {
+		return err
+	}
+	*info = Info(v)
+	info.Extra = extra
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. Extra is encoded after the known fields.
+func (info Info) MarshalJSON() ([]byte, error) {
+	type plain Info // plain does not have MarshalJSON
+	return marshalWithExtra(plain(info), info.Extra, infoFields)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Unknown fields are kept in Extra.
+func (o *Origin) UnmarshalJSON(data []byte) error {
+	type plain Origin // plain does not have UnmarshalJSON
+	var v plain
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, originFields)
+	if err != nil {
+		return err
+	}
+	*o = Origin(v)
+	o.Extra = extra
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. Extra is encoded after the known fields.
+func (o Origin) MarshalJSON() ([]byte, error) {
+	type plain Origin // plain does not have MarshalJSON
+	return marshalWithExtra(plain(o), o.Extra, originFields)
+}
+
+// unknownFields returns fields of the json object data which are not in known.
+// Field names are matched case-insensitively like encoding/json.
+func unknownFields(data []byte, known []string) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name := range fields {
+		if isKnownField(name, known) {
+			delete(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+func isKnownField(name string, known []string) bool {
+	for _, k := range known {
+		if strings.EqualFold(name, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// marshalWithExtra encodes v which is a struct and appends extra fields
+// to the object in sorted order. Extra fields which conflict with known are ignored.
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage, known []string) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		if !isKnownField(name, known) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return b, nil
+	}
+	sort.Strings(names)
+	b = b[:len(b)-1] // trim "}"
+	for _, name := range names {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 1 {
+			b = append(b, ',')
+		}
+		b = append(b, key...)
+		b = append(b, ':')
+		b = append(b, extra[name]...)
+	}
+	return append(b, '}'), nil
+}
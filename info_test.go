@@ -1,7 +1,9 @@
 package gopp
 
 import (
+	"encoding/json"
 	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -33,3 +35,83 @@ func TestProxy_AddInfoProxyHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestInfo_JSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "Version and Time",
+			in:   `{"Version":"v0.3.1","Time":"2018-08-15T10:47:33Z"}`,
+			want: `{"Version":"v0.3.1","Time":"2018-08-15T10:47:33Z"}`,
+		},
+		{
+			name: "Origin",
+			in: `{"Version":"v0.3.1","Time":"2018-08-15T10:47:33Z","Origin":{"VCS":"git",` +
+				`"URL":"https://github.com/BurntSushi/toml","Ref":"refs/tags/v0.3.1",` +
+				`"Hash":"3012a1dbe2e4bd1391d42b32f0577cb7bbc7f005","TagSum":"t1:abc"}}`,
+			want: `{"Version":"v0.3.1","Time":"2018-08-15T10:47:33Z","Origin":{"VCS":"git",` +
+				`"URL":"https://github.com/BurntSushi/toml","Hash":"3012a1dbe2e4bd1391d42b32f0577cb7bbc7f005",` +
+				`"TagSum":"t1:abc","Ref":"refs/tags/v0.3.1"}}`,
+		},
+		{
+			name: "Unknown fields",
+			in: `{"Zeta": [1, 2], "Version":"v0.3.1","Time":"2018-08-15T10:47:33Z",` +
+				`"Origin":{"VCS":"git","New":{"a":true}},"Alpha":"x"}`,
+			want: `{"Version":"v0.3.1","Time":"2018-08-15T10:47:33Z",` +
+				`"Origin":{"VCS":"git","New":{"a":true}},"Alpha":"x","Zeta":[1,2]}`,
+		},
+		{
+			name: "Case-insensitive known fields",
+			in:   `{"version":"v0.3.1","time":"2018-08-15T10:47:33Z"}`,
+			want: `{"Version":"v0.3.1","Time":"2018-08-15T10:47:33Z"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := body2VersionInfo(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("expected %s but got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInfo_MarshalJSON_conflict(t *testing.T) {
+	info := &Info{
+		Version: "v1.0.0",
+		Extra: map[string]json.RawMessage{
+			"version": json.RawMessage(`"v2.0.0"`),
+			"Extra":   json.RawMessage(`1`),
+		},
+	}
+	got, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Version":"v1.0.0","Time":"0001-01-01T00:00:00Z","Extra":1}`
+	if string(got) != want {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+}
+
+func TestProxy_infoPassThrough(t *testing.T) {
+	body := `{"Version":"v0.3.1","Time":"2018-08-15T10:47:33Z","Origin":{"VCS":"git","Hash":"abc"},"Extra":"x"}`
+	p, _, _ := newCacheTestProxy(t, NewMemoryStorage(), 0, http.StatusOK, body)
+	rec := serve(p, "/github.com/!burnt!sushi/toml/@v/v0.3.1.info")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if want := body + "\n"; rec.Body.String() != want {
+		t.Errorf("expected %s but got %s", want, rec.Body.String())
+	}
+}
@@ -0,0 +1,123 @@
+package gopp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/mod/semver"
+)
+
+// LatestMode represents how /@latest is resolved.
+type LatestMode int
+
+// Modes of resolving /@latest.
+const (
+	// LatestFromUpstream relays /@latest of the upstream. This is the default.
+	LatestFromUpstream LatestMode = iota
+	// LatestFallbackToList resolves /@latest from /@v/list when the upstream
+	// answers 404 or 410 to /@latest, e.g. static file mirrors.
+	LatestFallbackToList
+	// LatestFromList always resolves /@latest from /@v/list.
+	LatestFromList
+)
+
+// SetLatestMode sets how /@latest is resolved.
+// Versions pinned by Policy.PinLatest take precedence over any mode.
+func (p *Proxy) SetLatestMode(m LatestMode) error {
+	if m < LatestFromUpstream || m > LatestFromList {
+		return fmt.Errorf("unexpected latest mode: %d", m)
+	}
+	p.latest = m
+	return nil
+}
+
+// resolveLatest resolves /@latest of mr.Path according to the policy and the latest mode.
+func (p *Proxy) resolveLatest(ctx context.Context, mr *ModuleRequest) (*Info, error) {
+	info, err := p.resolveLatestInfo(ctx, mr)
+	if err != nil {
+		return nil, err
+	}
+	// @latest can be resolved from .info of the pinned version or @v/list,
+	// which are not validated as KindLatest by fetch.
+	if p.validations[KindLatest] {
+		if err := checkInfo(mr, info); err != nil {
+			return nil, p.validationError(mr, err)
+		}
+	}
+	return info, nil
+}
+
+func (p *Proxy) resolveLatestInfo(ctx context.Context, mr *ModuleRequest) (*Info, error) {
+	if p.policy != nil {
+		if v := p.policy.pinnedLatest(mr.Path); v != "" {
+			if err := p.policy.checkVersion(mr.Path, v); err != nil {
+				return nil, err
+			}
+			return p.fetchInfo(ctx, &ModuleRequest{Path: mr.Path, Version: v, Kind: KindInfo})
+		}
+	}
+	if p.latest == LatestFromList {
+		return p.latestFromList(ctx, mr)
+	}
+	info, err := p.fetchInfo(ctx, mr)
+	if err != nil {
+		var use *UpstreamStatusError
+		if p.latest == LatestFallbackToList && errors.As(err, &use) && use.NotFound() {
+			return p.latestFromList(ctx, mr)
+		}
+		return nil, err
+	}
+	if p.policy != nil && p.policy.checkVersion(mr.Path, info.Version) != nil {
+		// blocked version must never be returned as @latest.
+		return p.latestFromList(ctx, mr)
+	}
+	return info, nil
+}
+
+// latestFromList resolves @latest of mr.Path from @v/list in the same way as
+// the go command: the highest release is preferred to the highest pre-release.
+// Versions which are blocked by the policy are never chosen.
+func (p *Proxy) latestFromList(ctx context.Context, mr *ModuleRequest) (*Info, error) {
+	listReq := &ModuleRequest{Path: mr.Path, Kind: KindList}
+	body, err := p.fetch(ctx, listReq)
+	if err != nil {
+		return nil, err
+	}
+	vlist := body2VersionList(body)
+	body.Close()
+	if p.policy != nil {
+		vlist = p.policy.filter(mr.Path, vlist)
+	}
+	latest := highestVersion(vlist)
+	if latest == "" {
+		return nil, &statusError{
+			code: http.StatusNotFound,
+			err:  errors.New("no matching versions for query \"latest\""),
+		}
+	}
+	return p.fetchInfo(ctx, &ModuleRequest{Path: mr.Path, Version: latest, Kind: KindInfo})
+}
+
+// highestVersion returns the highest release in versionList.
+// The highest pre-release is returned when there is no release.
+func highestVersion(versionList []string) string {
+	var release, prerelease string
+	for _, v := range versionList {
+		if !semver.IsValid(v) {
+			continue
+		}
+		if semver.Prerelease(v) == "" {
+			if release == "" || semver.Compare(v, release) > 0 {
+				release = v
+			}
+		} else if prerelease == "" || semver.Compare(v, prerelease) > 0 {
+			prerelease = v
+		}
+	}
+	if release != "" {
+		return release
+	}
+	return prerelease
+}
@@ -0,0 +1,223 @@
+package gopp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHighestVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		list []string
+		want string
+	}{
+		{
+			name: "Release is preferred",
+			list: []string{"v1.0.0", "v1.2.0-rc.1", "v1.1.0", "invalid"},
+			want: "v1.1.0",
+		},
+		{
+			name: "Pre-release when no release",
+			list: []string{"v1.0.0-alpha", "v1.0.0-beta", "v0.0.0-20191109021931-daa7c04131f5"},
+			want: "v1.0.0-beta",
+		},
+		{
+			name: "Empty",
+			list: []string{"invalid"},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highestVersion(tt.list); got != tt.want {
+				t.Errorf("expected %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProxy_resolveLatest(t *testing.T) {
+	const (
+		v010Info = `{"Version":"v0.1.0","Time":"2019-01-01T00:00:00Z"}`
+		v020Info = `{"Version":"v0.2.0","Time":"2019-02-01T00:00:00Z"}`
+	)
+	withLatest := map[string]string{
+		"/example.com/foo/@latest":        v010Info,
+		"/example.com/foo/@v/list":        "v0.1.0\nv0.2.0\nv0.3.0-rc.1\n",
+		"/example.com/foo/@v/v0.1.0.info": v010Info,
+		"/example.com/foo/@v/v0.2.0.info": v020Info,
+	}
+	withoutLatest := map[string]string{
+		"/example.com/foo/@v/list":        "v0.1.0\nv0.2.0\nv0.3.0-rc.1\n",
+		"/example.com/foo/@v/v0.2.0.info": v020Info,
+	}
+	invalidInfo := map[string]string{
+		"/example.com/foo/@v/list":        "v0.1.0\nv0.2.0\n",
+		"/example.com/foo/@v/v0.1.0.info": `{"Version":"v0.1.0"}`,
+		"/example.com/foo/@v/v0.2.0.info": `{"Version":"v0.2","Time":"2019-02-01T00:00:00Z"}`,
+	}
+	tests := []struct {
+		name          string
+		responses     map[string]string
+		mode          LatestMode
+		pin           string
+		validate      bool // enable validation of KindLatest
+		wantCode      int
+		wantBody      string
+		wantRequested []string
+	}{
+		{
+			name:          "Upstream",
+			responses:     withLatest,
+			mode:          LatestFromUpstream,
+			wantCode:      http.StatusOK,
+			wantBody:      v010Info + "\n",
+			wantRequested: []string{"/example.com/foo/@latest"},
+		},
+		{
+			name:          "Upstream without @latest",
+			responses:     withoutLatest,
+			mode:          LatestFromUpstream,
+			wantCode:      http.StatusNotFound,
+			wantRequested: []string{"/example.com/foo/@latest"},
+		},
+		{
+			name:          "Fallback is not used when upstream has @latest",
+			responses:     withLatest,
+			mode:          LatestFallbackToList,
+			wantCode:      http.StatusOK,
+			wantBody:      v010Info + "\n",
+			wantRequested: []string{"/example.com/foo/@latest"},
+		},
+		{
+			name:      "Fallback to list",
+			responses: withoutLatest,
+			mode:      LatestFallbackToList,
+			wantCode:  http.StatusOK,
+			wantBody:  v020Info + "\n",
+			wantRequested: []string{
+				"/example.com/foo/@latest",
+				"/example.com/foo/@v/list",
+				"/example.com/foo/@v/v0.2.0.info",
+			},
+		},
+		{
+			name:      "From list",
+			responses: withLatest,
+			mode:      LatestFromList,
+			wantCode:  http.StatusOK,
+			wantBody:  v020Info + "\n",
+			wantRequested: []string{
+				"/example.com/foo/@v/list",
+				"/example.com/foo/@v/v0.2.0.info",
+			},
+		},
+		{
+			name:          "Pinned",
+			responses:     withLatest,
+			mode:          LatestFromList,
+			pin:           "v0.1.0",
+			wantCode:      http.StatusOK,
+			wantBody:      v010Info + "\n",
+			wantRequested: []string{"/example.com/foo/@v/v0.1.0.info"},
+		},
+		{
+			name:      "From list is validated",
+			responses: invalidInfo,
+			mode:      LatestFromList,
+			validate:  true,
+			wantCode:  http.StatusBadGateway,
+			wantRequested: []string{
+				"/example.com/foo/@v/list",
+				"/example.com/foo/@v/v0.2.0.info",
+			},
+		},
+		{
+			name:          "Pinned is validated",
+			responses:     invalidInfo,
+			pin:           "v0.1.0",
+			validate:      true,
+			wantCode:      http.StatusBadGateway,
+			wantRequested: []string{"/example.com/foo/@v/v0.1.0.info"},
+		},
+		{
+			name:          "Pinned to blocked version",
+			responses:     withLatest,
+			pin:           "v0.0.1",
+			wantCode:      http.StatusGone,
+			wantRequested: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			c := &mockClient{
+				DoMock: func(req *http.Request) (*http.Response, error) {
+					requested = append(requested, req.URL.Path)
+					body, ok := tt.responses[req.URL.Path]
+					if !ok {
+						return &http.Response{
+							StatusCode: http.StatusNotFound,
+							Body:       ioutil.NopCloser(strings.NewReader("not found")),
+						}, nil
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(strings.NewReader(body)),
+					}, nil
+				},
+			}
+			p, err := NewProxy(c, "https://localhost")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.SetLatestMode(tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			if tt.validate {
+				if err := p.EnableValidation(KindLatest); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.pin != "" {
+				pl := NewPolicy()
+				if err := pl.PinLatest("example.com", tt.pin); err != nil {
+					t.Fatal(err)
+				}
+				if err := pl.Deny("example.com", "<v0.1.0", ""); err != nil {
+					t.Fatal(err)
+				}
+				if err := p.AddPolicy(pl); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rec := serve(p, "/example.com/foo/@latest")
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d but got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("expected %q but got %q", tt.wantBody, rec.Body.String())
+			}
+			if !reflect.DeepEqual(requested, tt.wantRequested) {
+				t.Errorf("expected %v but got %v", tt.wantRequested, requested)
+			}
+		})
+	}
+}
+
+func TestProxy_SetLatestMode(t *testing.T) {
+	p := &Proxy{}
+	if err := p.SetLatestMode(LatestFromList + 1); err == nil {
+		t.Error("expected error for unexpected mode")
+	}
+	pl := NewPolicy()
+	if err := pl.PinLatest("", "v1.0.0"); err == nil {
+		t.Error("expected error for empty patterns")
+	}
+	if err := pl.PinLatest("example.com", "latest"); err == nil {
+		t.Error("expected error for invalid version")
+	}
+}
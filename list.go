@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+
+	"golang.org/x/mod/semver"
 )
 
 // ListProxyHandler represents proxy handler for /@v/list
@@ -21,6 +24,98 @@ func (p *Proxy) AddListProxy(h ListProxyHandler) error {
 	return nil
 }
 
+func defaultListProxyHandler() ListProxyHandler {
+	return func(w http.ResponseWriter, r *http.Request, versionList []string) error {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, v := range versionList {
+			if _, err := fmt.Fprintln(w, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ListTransformer rewrites the version list of /@v/list before it is passed
+// to ListProxyHandler.
+type ListTransformer func(versionList []string) []string
+
+// AddListTransformer registers transformers for /@v/list.
+// They are applied in the order of registration.
+//
+//	p.AddListTransformer(gopp.DropInvalidVersions, gopp.DedupVersions, gopp.SortVersions, gopp.LimitVersions(100))
+func (p *Proxy) AddListTransformer(ts ...ListTransformer) error {
+	for _, t := range ts {
+		if t == nil {
+			return errors.New("unexpected nil")
+		}
+	}
+	p.listTransformers = append(p.listTransformers, ts...)
+	return nil
+}
+
+// DropInvalidVersions drops versions which are not valid semantic versions.
+func DropInvalidVersions(versionList []string) []string {
+	return filterVersions(versionList, semver.IsValid)
+}
+
+// DropPseudoVersions drops pseudo-versions like v0.0.0-20191109021931-daa7c04131f5.
+func DropPseudoVersions(versionList []string) []string {
+	return filterVersions(versionList, func(v string) bool {
+		return !isPseudoVersion(v)
+	})
+}
+
+// DropPrereleaseVersions drops pre-release versions like v1.0.0-rc.1.
+// Pseudo-versions are also dropped because they are pre-release versions.
+func DropPrereleaseVersions(versionList []string) []string {
+	return filterVersions(versionList, func(v string) bool {
+		return semver.Prerelease(v) == ""
+	})
+}
+
+// DedupVersions drops duplicated versions. The first one is kept.
+func DedupVersions(versionList []string) []string {
+	seen := make(map[string]bool, len(versionList))
+	return filterVersions(versionList, func(v string) bool {
+		if seen[v] {
+			return false
+		}
+		seen[v] = true
+		return true
+	})
+}
+
+// SortVersions sorts versions in ascending order of the semantic versioning.
+// Invalid versions are placed first.
+func SortVersions(versionList []string) []string {
+	sort.SliceStable(versionList, func(i, j int) bool {
+		return semver.Compare(versionList[i], versionList[j]) < 0
+	})
+	return versionList
+}
+
+// LimitVersions returns ListTransformer which keeps the last n versions.
+// They are the n highest versions when it is applied after SortVersions.
+func LimitVersions(n int) ListTransformer {
+	return func(versionList []string) []string {
+		if n < 0 || len(versionList) <= n {
+			return versionList
+		}
+		return versionList[len(versionList)-n:]
+	}
+}
+
+func filterVersions(versionList []string, keep func(v string) bool) []string {
+	ret := versionList[:0]
+	for _, v := range versionList {
+		if keep(v) {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
 func body2VersionList(body io.Reader) []string {
 	ret := make([]string, 0)
 	scanner := bufio.NewScanner(body)
@@ -30,17 +125,20 @@ func body2VersionList(body io.Reader) []string {
 	return ret
 }
 
-func (p *Proxy) versionListProxy(w http.ResponseWriter, r *http.Request) error {
+func (p *Proxy) versionListProxy(w http.ResponseWriter, r *http.Request, mr *ModuleRequest) error {
 	// /golang.org/x/net/@v/list
-	resp, err := p.request(r.URL.Path)
+	body, err := p.fetch(r.Context(), mr)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %s", resp.Status)
+	defer body.Close()
+	vlist := body2VersionList(body)
+	if p.policy != nil {
+		vlist = p.policy.filter(mr.Path, vlist)
+	}
+	for _, t := range p.listTransformers {
+		vlist = t(vlist)
 	}
-	vlist := body2VersionList(resp.Body)
 	if err := p.versionListHandler(w, r, vlist); err != nil {
 		return err
 	}
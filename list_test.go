@@ -1,7 +1,10 @@
 package gopp
 
 import (
+	"io/ioutil"
 	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -33,3 +36,88 @@ func TestProxy_AddListProxy(t *testing.T) {
 		})
 	}
 }
+
+func TestListTransformers(t *testing.T) {
+	list := func() []string {
+		return []string{
+			"v1.0.0", "v0.9.0", "invalid", "v1.1.0-rc.1", "v1.0.0",
+			"v0.0.0-20191109021931-daa7c04131f5", "v1.2.0", "",
+		}
+	}
+	tests := []struct {
+		name string
+		t    ListTransformer
+		want []string
+	}{
+		{
+			name: "DropInvalidVersions",
+			t:    DropInvalidVersions,
+			want: []string{"v1.0.0", "v0.9.0", "v1.1.0-rc.1", "v1.0.0", "v0.0.0-20191109021931-daa7c04131f5", "v1.2.0"},
+		},
+		{
+			name: "DropPseudoVersions",
+			t:    DropPseudoVersions,
+			want: []string{"v1.0.0", "v0.9.0", "invalid", "v1.1.0-rc.1", "v1.0.0", "v1.2.0", ""},
+		},
+		{
+			name: "DropPrereleaseVersions",
+			t:    DropPrereleaseVersions,
+			want: []string{"v1.0.0", "v0.9.0", "invalid", "v1.0.0", "v1.2.0", ""},
+		},
+		{
+			name: "DedupVersions",
+			t:    DedupVersions,
+			want: []string{"v1.0.0", "v0.9.0", "invalid", "v1.1.0-rc.1", "v0.0.0-20191109021931-daa7c04131f5", "v1.2.0", ""},
+		},
+		{
+			name: "SortVersions",
+			t:    SortVersions,
+			want: []string{"invalid", "", "v0.0.0-20191109021931-daa7c04131f5", "v0.9.0", "v1.0.0", "v1.0.0", "v1.1.0-rc.1", "v1.2.0"},
+		},
+		{
+			name: "LimitVersions",
+			t:    LimitVersions(2),
+			want: []string{"v1.2.0", ""},
+		},
+		{
+			name: "LimitVersions more than length",
+			t:    LimitVersions(100),
+			want: list(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.t(list()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProxy_AddListTransformer(t *testing.T) {
+	c := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader("v1.0.0\nv0.1.0\nv1.0.0\nv1.1.0-rc.1\nbad\nv0.2.0\n")),
+			}, nil
+		},
+	}
+	p, err := NewProxy(c, "https://proxy.golang.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddListTransformer(DropInvalidVersions, nil); err == nil {
+		t.Error("expected error for nil transformer")
+	}
+	if err := p.AddListTransformer(DropInvalidVersions, DropPrereleaseVersions, DedupVersions, SortVersions, LimitVersions(2)); err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(p, "/example.com/foo/@v/list")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if want := "v0.2.0\nv1.0.0\n"; rec.Body.String() != want {
+		t.Errorf("expected %q but got %q", want, rec.Body.String())
+	}
+}
@@ -0,0 +1,208 @@
+package gopp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metricsPath is the url path where the registered Metrics is served.
+const metricsPath = "/metrics"
+
+// Metric names which are collected by the proxy.
+const (
+	metricRequests         = "gopp_requests_total"
+	metricRequestDuration  = "gopp_request_duration_seconds"
+	metricResponseBytes    = "gopp_response_bytes_total"
+	metricUpstreamRequests = "gopp_upstream_requests_total"
+	metricUpstreamDuration = "gopp_upstream_request_duration_seconds"
+	metricCacheRequests    = "gopp_cache_requests_total"
+	metricModuleDownloads  = "gopp_module_downloads_total"
+)
+
+type metricDesc struct {
+	typ  string // "counter" or "histogram"
+	help string
+}
+
+var metricDescs = map[string]metricDesc{
+	metricRequests:         {typ: "counter", help: "Number of requests by endpoint kind and status code."},
+	metricRequestDuration:  {typ: "histogram", help: "Latency of requests by endpoint kind."},
+	metricResponseBytes:    {typ: "counter", help: "Bytes sent to clients by endpoint kind."},
+	metricUpstreamRequests: {typ: "counter", help: "Number of upstream requests by upstream and status code."},
+	metricUpstreamDuration: {typ: "histogram", help: "Latency until the upstream responds headers by upstream."},
+	metricCacheRequests:    {typ: "counter", help: "Number of cache lookups by endpoint kind and result."},
+	metricModuleDownloads:  {typ: "counter", help: "Number of zips served by module path."},
+}
+
+// defaultBuckets are the upper bounds of histogram buckets in seconds.
+var defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// Metrics collects counters and histograms of the proxy and exposes them in
+// the Prometheus text format. It is served at /metrics of the proxy when it is
+// registered by AddMetrics, and it can be mounted elsewhere as http.Handler.
+type Metrics struct {
+	mu         sync.Mutex
+	counters   map[string]map[string]float64    // name -> labels -> value
+	histograms map[string]map[string]*histogram // name -> labels -> histogram
+}
+
+type histogram struct {
+	counts []uint64 // counts[i] is the number of observations in (buckets[i-1], buckets[i]]
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics makes empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		counters:   make(map[string]map[string]float64),
+		histograms: make(map[string]map[string]*histogram),
+	}
+}
+
+// AddMetrics registers metrics which is collected by the proxy and served at /metrics.
+func (p *Proxy) AddMetrics(m *Metrics) error {
+	if m == nil {
+		return errors.New("unexpected nil")
+	}
+	p.metrics = m
+	return nil
+}
+
+// add adds v to the counter. labels are pairs of the label name and value.
+func (m *Metrics) add(name string, v float64, labels ...string) {
+	key := formatLabels(labels)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.counters[name]
+	if !ok {
+		c = make(map[string]float64)
+		m.counters[name] = c
+	}
+	c[key] += v
+}
+
+// observe observes v to the histogram. labels are pairs of the label name and value.
+func (m *Metrics) observe(name string, v float64, labels ...string) {
+	key := formatLabels(labels)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hs, ok := m.histograms[name]
+	if !ok {
+		hs = make(map[string]*histogram)
+		m.histograms[name] = hs
+	}
+	h, ok := hs[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(defaultBuckets))}
+		hs[key] = h
+	}
+	for i, le := range defaultBuckets {
+		if v <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// ServeHTTP implements http.Handler.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes metrics in the Prometheus text format to w.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	m.mu.Lock()
+	names := make([]string, 0, len(m.counters)+len(m.histograms))
+	for name := range m.counters {
+		names = append(names, name)
+	}
+	for name := range m.histograms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		desc := metricDescs[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, desc.help, name, desc.typ)
+		if c, ok := m.counters[name]; ok {
+			for _, key := range sortedKeys(c) {
+				fmt.Fprintf(&b, "%s%s %s\n", name, key, formatFloat(c[key]))
+			}
+			continue
+		}
+		hs := m.histograms[name]
+		keys := make([]string, 0, len(hs))
+		for key := range hs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			h := hs[key]
+			var cumulative uint64
+			for i, le := range defaultBuckets {
+				cumulative += h.counts[i]
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, withLabel(key, "le", formatFloat(le)), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, withLabel(key, "le", "+Inf"), h.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, key, formatFloat(h.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, key, h.count)
+		}
+	}
+	m.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func sortedKeys(c map[string]float64) []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats pairs of the label name and value like {kind="zip",code="200"}.
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(labelValueReplacer.Replace(labels[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLabel appends the label to the formatted labels.
+func withLabel(key, name, value string) string {
+	label := formatLabels([]string{name, value})
+	if key == "" {
+		return label
+	}
+	return key[:len(key)-1] + "," + label[1:]
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,92 @@
+package gopp
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxy_AddMetrics(t *testing.T) {
+	p, _, _ := newCacheTestProxy(t, NewMemoryStorage(), time.Minute, http.StatusOK, "zip")
+	if err := p.AddMetrics(nil); err == nil {
+		t.Error("expected error for nil")
+	}
+	if rec := serve(p, "/metrics"); rec.Code == http.StatusOK {
+		t.Errorf("/metrics must not be served before AddMetrics")
+	}
+	if err := p.AddMetrics(NewMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	serve(p, "/github.com/!burnt!sushi/toml/@v/v0.3.1.zip")
+	serve(p, "/github.com/!burnt!sushi/toml/@v/v0.3.1.zip")
+
+	rec := serve(p, "/metrics")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d but got %d", http.StatusOK, rec.Code)
+	}
+	got := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE gopp_requests_total counter\n",
+		`gopp_requests_total{kind="zip",code="200"} 2` + "\n",
+		`gopp_response_bytes_total{kind="zip"} 6` + "\n",
+		`gopp_cache_requests_total{kind="zip",result="hit"} 1` + "\n",
+		`gopp_cache_requests_total{kind="zip",result="miss"} 1` + "\n",
+		`gopp_upstream_requests_total{upstream="https://localhost",code="200"} 1` + "\n",
+		`gopp_module_downloads_total{module="github.com/BurntSushi/toml"} 2` + "\n",
+		"# TYPE gopp_request_duration_seconds histogram\n",
+		`gopp_request_duration_seconds_bucket{kind="zip",le="+Inf"} 2` + "\n",
+		`gopp_request_duration_seconds_count{kind="zip"} 2` + "\n",
+		`gopp_upstream_request_duration_seconds_count{upstream="https://localhost"} 1` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in\n%s", want, got)
+		}
+	}
+}
+
+func TestMetrics_histogram(t *testing.T) {
+	m := NewMetrics()
+	m.observe(metricRequestDuration, 0.003, "kind", "info")
+	m.observe(metricRequestDuration, 0.2, "kind", "info")
+	m.observe(metricRequestDuration, 100, "kind", "info")
+	var b strings.Builder
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`gopp_request_duration_seconds_bucket{kind="info",le="0.005"} 1` + "\n",
+		`gopp_request_duration_seconds_bucket{kind="info",le="0.1"} 1` + "\n",
+		`gopp_request_duration_seconds_bucket{kind="info",le="0.25"} 2` + "\n",
+		`gopp_request_duration_seconds_bucket{kind="info",le="60"} 2` + "\n",
+		`gopp_request_duration_seconds_bucket{kind="info",le="+Inf"} 3` + "\n",
+		`gopp_request_duration_seconds_sum{kind="info"} 100.203` + "\n",
+		`gopp_request_duration_seconds_count{kind="info"} 3` + "\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected %q in\n%s", want, b.String())
+		}
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	tests := []struct {
+		labels []string
+		want   string
+	}{
+		{labels: nil, want: ""},
+		{labels: []string{"kind", "zip"}, want: `{kind="zip"}`},
+		{labels: []string{"module", `a"b\c` + "\n", "code", "200"}, want: `{module="a\"b\\c\n",code="200"}`},
+	}
+	for _, tt := range tests {
+		if got := formatLabels(tt.labels); got != tt.want {
+			t.Errorf("expected %s but got %s", tt.want, got)
+		}
+	}
+	if got, want := withLabel("", "le", "1"), `{le="1"}`; got != want {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+	if got, want := withLabel(`{kind="zip"}`, "le", "1"), `{kind="zip",le="1"}`; got != want {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+}
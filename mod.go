@@ -2,7 +2,6 @@ package gopp
 
 import (
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 )
@@ -21,17 +20,22 @@ func (p *Proxy) AddModProxyHandler(h ModProxyHandler) error {
 	return nil
 }
 
-func (p *Proxy) versionModProxy(w http.ResponseWriter, r *http.Request) error {
+func defaultModProxyHandler() ModProxyHandler {
+	return func(w http.ResponseWriter, r *http.Request, body io.Reader) error {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, err := io.Copy(w, body)
+		return err
+	}
+}
+
+func (p *Proxy) versionModProxy(w http.ResponseWriter, r *http.Request, mr *ModuleRequest) error {
 	// /golang.org/x/net/@v/v0.0.1.mod
-	resp, err := p.request(r.URL.Path)
+	body, err := p.fetch(r.Context(), mr)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %s", resp.Status)
-	}
-	if err := p.versionModHandler(w, r, resp.Body); err != nil {
+	defer body.Close()
+	if err := p.versionModHandler(w, r, body); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,257 @@
+package gopp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// PolicyError represents that the module or the version is blocked by Policy.
+type PolicyError struct {
+	Path    string
+	Version string // empty when the module itself is blocked
+	Reason  string
+}
+
+func (e *PolicyError) Error() string {
+	target := e.Path
+	if e.Version != "" {
+		target += "@" + e.Version
+	}
+	if e.Reason == "" {
+		return fmt.Sprintf("%s is blocked by policy", target)
+	}
+	return fmt.Sprintf("%s is blocked by policy: %s", target, e.Reason)
+}
+
+// StatusCode returns 403 when the module is blocked and 410 when the version is blocked.
+func (e *PolicyError) StatusCode() int {
+	if e.Version == "" {
+		return http.StatusForbidden
+	}
+	return http.StatusGone
+}
+
+// Policy decides which modules and versions can be fetched.
+// Patterns are comma-separated glob patterns of module path prefixes
+// like GOPRIVATE. e.g. "*.corp.example.com,rsc.io/private"
+type Policy struct {
+	allow []string
+	deny  []*denyRule
+	pins  []*pinRule
+}
+
+type denyRule struct {
+	patterns   string
+	constraint versionConstraint // nil denies the module itself
+	reason     string
+}
+
+type pinRule struct {
+	patterns string
+	version  string
+}
+
+// NewPolicy makes a policy which allows all modules.
+func NewPolicy() *Policy {
+	return &Policy{}
+}
+
+// AddPolicy registers policy which is evaluated before requesting the upstream.
+func (p *Proxy) AddPolicy(pl *Policy) error {
+	if pl == nil {
+		return errors.New("unexpected nil")
+	}
+	p.policy = pl
+	return nil
+}
+
+// Allow restricts modules which can be fetched to patterns.
+// Allow can be called multiple times. All modules are allowed when it is never called.
+func (pl *Policy) Allow(patterns string) error {
+	if strings.TrimSpace(patterns) == "" {
+		return errors.New("empty patterns")
+	}
+	pl.allow = append(pl.allow, patterns)
+	return nil
+}
+
+// Deny blocks versions of the modules which match patterns.
+// constraint is like ">=v1.2.0 <v1.2.5 || v1.3.0". The module itself is blocked
+// when constraint is empty. reason is responded to the client.
+func (pl *Policy) Deny(patterns, constraint, reason string) error {
+	if strings.TrimSpace(patterns) == "" {
+		return errors.New("empty patterns")
+	}
+	rule := &denyRule{
+		patterns: patterns,
+		reason:   reason,
+	}
+	if constraint != "" {
+		c, err := parseVersionConstraint(constraint)
+		if err != nil {
+			return err
+		}
+		rule.constraint = c
+	}
+	pl.deny = append(pl.deny, rule)
+	return nil
+}
+
+// PinLatest pins @latest of the modules which match patterns to version.
+// The upstream @latest is never requested for them. The first matched pin is used
+// when PinLatest is called multiple times.
+func (pl *Policy) PinLatest(patterns, version string) error {
+	if strings.TrimSpace(patterns) == "" {
+		return errors.New("empty patterns")
+	}
+	if !semver.IsValid(version) {
+		return fmt.Errorf("invalid version: %q", version)
+	}
+	pl.pins = append(pl.pins, &pinRule{patterns: patterns, version: version})
+	return nil
+}
+
+// pinnedLatest returns the version which @latest of the module is pinned to.
+// It returns empty string when the module is not pinned.
+func (pl *Policy) pinnedLatest(modPath string) string {
+	for _, pin := range pl.pins {
+		if module.MatchPrefixPatterns(pin.patterns, modPath) {
+			return pin.version
+		}
+	}
+	return ""
+}
+
+// checkModule returns *PolicyError when the module is blocked.
+func (pl *Policy) checkModule(modPath string) error {
+	if len(pl.allow) > 0 {
+		allowed := false
+		for _, patterns := range pl.allow {
+			if module.MatchPrefixPatterns(patterns, modPath) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &PolicyError{Path: modPath, Reason: "module is not allowed"}
+		}
+	}
+	for _, rule := range pl.deny {
+		if rule.constraint == nil && module.MatchPrefixPatterns(rule.patterns, modPath) {
+			return &PolicyError{Path: modPath, Reason: rule.reason}
+		}
+	}
+	return nil
+}
+
+// checkVersion returns *PolicyError when the version of the module is blocked.
+func (pl *Policy) checkVersion(modPath, version string) error {
+	if err := pl.checkModule(modPath); err != nil {
+		return err
+	}
+	for _, rule := range pl.deny {
+		if rule.constraint != nil && rule.constraint.match(version) &&
+			module.MatchPrefixPatterns(rule.patterns, modPath) {
+			return &PolicyError{Path: modPath, Version: version, Reason: rule.reason}
+		}
+	}
+	return nil
+}
+
+func (pl *Policy) check(mr *ModuleRequest) error {
+	if mr.Version == "" {
+		return pl.checkModule(mr.Path)
+	}
+	return pl.checkVersion(mr.Path, mr.Version)
+}
+
+// filter returns versions which are not blocked.
+func (pl *Policy) filter(modPath string, versionList []string) []string {
+	ret := make([]string, 0, len(versionList))
+	for _, v := range versionList {
+		if pl.checkVersion(modPath, v) == nil {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// versionConstraint is OR of AND of the comparisons.
+type versionConstraint [][]versionComparison
+
+type versionComparison struct {
+	op      string
+	version string
+}
+
+var constraintOps = []string{">=", "<=", "!=", ">", "<", "="}
+
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	var c versionConstraint
+	for _, group := range strings.Split(s, "||") {
+		fields := strings.FieldsFunc(group, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("unexpected version constraint: %q", s)
+		}
+		var and []versionComparison
+		for _, f := range fields {
+			cmp := versionComparison{op: "=", version: f}
+			for _, op := range constraintOps {
+				if strings.HasPrefix(f, op) {
+					cmp = versionComparison{op: op, version: strings.TrimPrefix(f, op)}
+					break
+				}
+			}
+			if !semver.IsValid(cmp.version) {
+				return nil, fmt.Errorf("unexpected semantic version format in constraint: %s", f)
+			}
+			and = append(and, cmp)
+		}
+		c = append(c, and)
+	}
+	return c, nil
+}
+
+func (c versionConstraint) match(version string) bool {
+	if !semver.IsValid(version) {
+		return false
+	}
+	for _, and := range c {
+		matched := true
+		for _, cmp := range and {
+			if !cmp.match(version) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmp versionComparison) match(version string) bool {
+	n := semver.Compare(version, cmp.version)
+	switch cmp.op {
+	case ">=":
+		return n >= 0
+	case "<=":
+		return n <= 0
+	case "!=":
+		return n != 0
+	case ">":
+		return n > 0
+	case "<":
+		return n < 0
+	}
+	return n == 0
+}
@@ -0,0 +1,222 @@
+package gopp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVersionConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+		wantErr    bool
+	}{
+		{constraint: "v1.2.3", version: "v1.2.3", want: true},
+		{constraint: "=v1.2.3", version: "v1.2.4", want: false},
+		{constraint: ">=v1.2.0 <v1.2.5", version: "v1.2.4", want: true},
+		{constraint: ">=v1.2.0, <v1.2.5", version: "v1.2.5", want: false},
+		{constraint: "<v1.0.0 || v1.3.0", version: "v1.3.0", want: true},
+		{constraint: "<v1.0.0 || v1.3.0", version: "v0.9.0", want: true},
+		{constraint: "!=v1.0.0", version: "v1.0.0", want: false},
+		{constraint: ">v1.0.0", version: "v1.0.1-rc.1", want: true},
+		{constraint: "<=v1.0.0", version: "invalid", want: false},
+		{constraint: ">=1.0.0", wantErr: true},
+		{constraint: "v1.0.0 ||", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			c, err := parseVersionConstraint(tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVersionConstraint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := c.match(tt.version); got != tt.want {
+				t.Errorf("expected %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func newTestPolicy(t *testing.T) *Policy {
+	t.Helper()
+	pl := NewPolicy()
+	if err := pl.Allow("github.com/pkg,golang.org/x/*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pl.Deny("golang.org/x/crypto", "", "use github.com/pkg/crypto"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pl.Deny("github.com/pkg/errors", ">=v0.0.2 <v0.0.4", "CVE-0000-0000"); err != nil {
+		t.Fatal(err)
+	}
+	return pl
+}
+
+func TestPolicy_check(t *testing.T) {
+	tests := []struct {
+		name     string
+		mr       *ModuleRequest
+		wantCode int
+	}{
+		{
+			name: "Allowed",
+			mr:   &ModuleRequest{Path: "github.com/pkg/errors", Version: "v0.0.1", Kind: KindZip},
+		},
+		{
+			name: "Allowed by glob",
+			mr:   &ModuleRequest{Path: "golang.org/x/net", Kind: KindList},
+		},
+		{
+			name:     "Not allowed",
+			mr:       &ModuleRequest{Path: "github.com/evil/errors", Kind: KindList},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Denied module",
+			mr:       &ModuleRequest{Path: "golang.org/x/crypto", Version: "v0.0.1", Kind: KindMod},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Denied version",
+			mr:       &ModuleRequest{Path: "github.com/pkg/errors", Version: "v0.0.3", Kind: KindInfo},
+			wantCode: http.StatusGone,
+		},
+		{
+			name: "List is not denied by version",
+			mr:   &ModuleRequest{Path: "github.com/pkg/errors", Kind: KindList},
+		},
+	}
+	pl := newTestPolicy(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := pl.check(tt.mr)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			pe, ok := err.(*PolicyError)
+			if !ok {
+				t.Fatalf("expected *PolicyError but got %T", err)
+			}
+			if pe.StatusCode() != tt.wantCode {
+				t.Errorf("expected %d but got %d", tt.wantCode, pe.StatusCode())
+			}
+		})
+	}
+}
+
+func TestPolicy_errors(t *testing.T) {
+	pl := NewPolicy()
+	if err := pl.Allow(" "); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+	if err := pl.Deny("", "", ""); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+	if err := pl.Deny("github.com/pkg/errors", "latest", ""); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+	if err := (&Proxy{}).AddPolicy(nil); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+func newPolicyTestProxy(t *testing.T, responses map[string]string) (*Proxy, *[]string) {
+	t.Helper()
+	var requested []string
+	c := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			requested = append(requested, req.URL.Path)
+			body, ok := responses[req.URL.Path]
+			if !ok {
+				return &http.Response{
+					StatusCode: http.StatusNotFound,
+					Body:       ioutil.NopCloser(strings.NewReader("not found")),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+			}, nil
+		},
+	}
+	p, err := NewProxy(c, "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddPolicy(newTestPolicy(t)); err != nil {
+		t.Fatal(err)
+	}
+	return p, &requested
+}
+
+func TestProxy_policy(t *testing.T) {
+	responses := map[string]string{
+		"/github.com/pkg/errors/@v/list":        "v0.0.1\nv0.0.2\nv0.0.3\nv0.0.5-rc.1\n",
+		"/github.com/pkg/errors/@latest":        `{"Version":"v0.0.3","Time":"2019-01-02T22:52:24-08:00"}`,
+		"/github.com/pkg/errors/@v/v0.0.1.info": `{"Version":"v0.0.1","Time":"2019-01-01T22:52:24-08:00"}`,
+		"/github.com/pkg/errors/@v/v0.0.3.zip":  "zip",
+	}
+	tests := []struct {
+		name          string
+		urlPath       string
+		wantCode      int
+		wantBody      string
+		wantRequested []string
+	}{
+		{
+			name:          "Blocked version is not requested",
+			urlPath:       "/github.com/pkg/errors/@v/v0.0.3.zip",
+			wantCode:      http.StatusGone,
+			wantBody:      "github.com/pkg/errors@v0.0.3 is blocked by policy: CVE-0000-0000\n",
+			wantRequested: nil,
+		},
+		{
+			name:          "Not allowed module is not requested",
+			urlPath:       "/github.com/evil/errors/@v/list",
+			wantCode:      http.StatusForbidden,
+			wantRequested: nil,
+		},
+		{
+			name:          "List is filtered",
+			urlPath:       "/github.com/pkg/errors/@v/list",
+			wantCode:      http.StatusOK,
+			wantBody:      "v0.0.1\nv0.0.5-rc.1\n",
+			wantRequested: []string{"/github.com/pkg/errors/@v/list"},
+		},
+		{
+			name:     "Blocked latest is resolved from list",
+			urlPath:  "/github.com/pkg/errors/@latest",
+			wantCode: http.StatusOK,
+			wantBody: `{"Version":"v0.0.1","Time":"2019-01-01T22:52:24-08:00"}` + "\n",
+			wantRequested: []string{
+				"/github.com/pkg/errors/@latest",
+				"/github.com/pkg/errors/@v/list",
+				"/github.com/pkg/errors/@v/v0.0.1.info",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, requested := newPolicyTestProxy(t, responses)
+			rec := serve(p, tt.urlPath)
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected %d but got %d", tt.wantCode, rec.Code)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("expected %q but got %q", tt.wantBody, rec.Body.String())
+			}
+			if !reflect.DeepEqual(*requested, tt.wantRequested) {
+				t.Errorf("expected %v but got %v", tt.wantRequested, *requested)
+			}
+		})
+	}
+}
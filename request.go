@@ -0,0 +1,148 @@
+package gopp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// Kind represents kind of the GOPROXY endpoint.
+type Kind int
+
+// Kinds of the GOPROXY endpoint. see `go help goproxy`
+const (
+	KindLatest  Kind = iota + 1 // /@latest
+	KindList                    // /@v/list
+	KindInfo                    // /@v/v0.0.1.info
+	KindMod                     // /@v/v0.0.1.mod
+	KindZip                     // /@v/v0.0.1.zip
+	KindZipHash                 // /@v/v0.0.1.ziphash. served only when EnableZipHash is called
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindLatest:
+		return "latest"
+	case KindList:
+		return "list"
+	case KindInfo:
+		return "info"
+	case KindMod:
+		return "mod"
+	case KindZip:
+		return "zip"
+	case KindZipHash:
+		return "ziphash"
+	}
+	return "unknown"
+}
+
+// ModuleRequest represents decoded request of the GOPROXY protocol.
+// It is filled in once by the proxy and handlers can receive it by ModuleRequestFromContext.
+type ModuleRequest struct {
+	Path    string // module path like github.com/BurntSushi/toml
+	Version string // version like v0.3.1. empty when Kind is KindLatest or KindList
+	Kind    Kind
+}
+
+// URLPath returns the url path of mr which is encoded by the case-encoding.
+// e.g. /github.com/!burnt!sushi/toml/@v/v0.3.1.info
+func (mr *ModuleRequest) URLPath() (string, error) {
+	escapedPath, err := module.EscapePath(mr.Path)
+	if err != nil {
+		return "", err
+	}
+	switch mr.Kind {
+	case KindLatest:
+		return "/" + escapedPath + "/@latest", nil
+	case KindList:
+		return "/" + escapedPath + "/@v/list", nil
+	}
+	escapedVersion, err := module.EscapeVersion(mr.Version)
+	if err != nil {
+		return "", err
+	}
+	return "/" + escapedPath + "/@v/" + escapedVersion + "." + mr.Kind.String(), nil
+}
+
+type moduleRequestKey struct{}
+
+// ModuleRequestFromContext returns ModuleRequest which is decoded from the request url path.
+// ctx is expected to be the context of *http.Request passed to the proxy handlers.
+func ModuleRequestFromContext(ctx context.Context) (*ModuleRequest, bool) {
+	mr, ok := ctx.Value(moduleRequestKey{}).(*ModuleRequest)
+	return mr, ok
+}
+
+func withModuleRequest(r *http.Request, mr *ModuleRequest) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), moduleRequestKey{}, mr))
+}
+
+// parseModuleRequest parses url path like /github.com/!burnt!sushi/toml/@v/v0.3.1.info
+// and decodes the case-encoding of module path and version.
+func parseModuleRequest(urlPath string) (*ModuleRequest, error) {
+	p := strings.TrimPrefix(urlPath, "/")
+	var (
+		mr             ModuleRequest
+		escapedPath    string
+		escapedVersion string
+	)
+	switch {
+	case strings.HasSuffix(p, "/@latest"):
+		escapedPath = strings.TrimSuffix(p, "/@latest")
+		mr.Kind = KindLatest
+	case strings.HasSuffix(p, "/@v/list"):
+		escapedPath = strings.TrimSuffix(p, "/@v/list")
+		mr.Kind = KindList
+	default:
+		i := strings.LastIndex(p, "/@v/")
+		if i < 0 {
+			return nil, errors.New("unexpected url path")
+		}
+		escapedPath = p[:i]
+		basename := p[i+len("/@v/"):]
+		// expected path like /@v/v0.0.1.info
+		if strings.Contains(basename, "/") {
+			return nil, fmt.Errorf("unexpected module path: %s", urlPath)
+		}
+		fileExt := path.Ext(basename)
+		escapedVersion = strings.TrimSuffix(basename, fileExt)
+		switch fileExt {
+		case ".info":
+			mr.Kind = KindInfo
+		case ".mod":
+			mr.Kind = KindMod
+		case ".zip":
+			mr.Kind = KindZip
+		case ".ziphash":
+			mr.Kind = KindZipHash
+		default:
+			return nil, errors.New("unexpected url path")
+		}
+	}
+
+	modPath, err := module.UnescapePath(escapedPath)
+	if err != nil {
+		return nil, &statusError{code: http.StatusBadRequest, err: err}
+	}
+	mr.Path = modPath
+	if escapedVersion == "" {
+		return &mr, nil
+	}
+	version, err := module.UnescapeVersion(escapedVersion)
+	if err != nil {
+		return nil, &statusError{code: http.StatusBadRequest, err: err}
+	}
+	// expected semantic version format like v1.0.0
+	if !semver.IsValid(version) {
+		return nil, fmt.Errorf("unexpected semantic version format: %s", version)
+	}
+	mr.Version = version
+	return &mr, nil
+}
@@ -0,0 +1,208 @@
+package gopp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseModuleRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		urlPath  string
+		want     *ModuleRequest
+		wantErr  bool
+		wantCode int
+	}{
+		{
+			name:    "Valid /@latest",
+			urlPath: "/github.com/pkg/errors/@latest",
+			want:    &ModuleRequest{Path: "github.com/pkg/errors", Kind: KindLatest},
+		},
+		{
+			name:    "Valid /@v/list",
+			urlPath: "/github.com/!burnt!sushi/toml/@v/list",
+			want:    &ModuleRequest{Path: "github.com/BurntSushi/toml", Kind: KindList},
+		},
+		{
+			name:    "Valid /@v/v0.3.1.info",
+			urlPath: "/github.com/!burnt!sushi/toml/@v/v0.3.1.info",
+			want:    &ModuleRequest{Path: "github.com/BurntSushi/toml", Version: "v0.3.1", Kind: KindInfo},
+		},
+		{
+			name:    "Valid /@v/v1.0.0-!r!c1.mod",
+			urlPath: "/github.com/pkg/errors/@v/v1.0.0-!r!c1.mod",
+			want:    &ModuleRequest{Path: "github.com/pkg/errors", Version: "v1.0.0-RC1", Kind: KindMod},
+		},
+		{
+			name:    "Valid /@v/v2.0.0+incompatible.zip",
+			urlPath: "github.com/pkg/errors/@v/v2.0.0+incompatible.zip",
+			want:    &ModuleRequest{Path: "github.com/pkg/errors", Version: "v2.0.0+incompatible", Kind: KindZip},
+		},
+		{
+			name:    "Valid /@v/v0.8.1.ziphash",
+			urlPath: "/github.com/pkg/errors/@v/v0.8.1.ziphash",
+			want:    &ModuleRequest{Path: "github.com/pkg/errors", Version: "v0.8.1", Kind: KindZipHash},
+		},
+		{
+			name:     "Invalid escaped path",
+			urlPath:  "/github.com/BurntSushi/toml/@v/list",
+			wantErr:  true,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid escaped version",
+			urlPath:  "/github.com/pkg/errors/@v/v1.0.0-RC1.info",
+			wantErr:  true,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid escape sequence",
+			urlPath:  "/github.com/pkg/errors!/@latest",
+			wantErr:  true,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:    "Invalid version format",
+			urlPath: "/github.com/pkg/errors/@v/0.0.1.info",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid extension",
+			urlPath: "/github.com/pkg/errors/@v/v0.0.1.svg",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid path",
+			urlPath: "/github.com/pkg/errors",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseModuleRequest(tt.urlPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseModuleRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantCode != 0 {
+				se, ok := err.(*statusError)
+				if !ok {
+					t.Fatalf("expected *statusError but got %T", err)
+				}
+				if se.StatusCode() != tt.wantCode {
+					t.Errorf("expected %d but got %d", tt.wantCode, se.StatusCode())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v but got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProxy_ServeHTTP_invalidEscaping(t *testing.T) {
+	c := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			t.Errorf("unexpected upstream request: %s", req.URL)
+			return nil, nil
+		},
+	}
+	p, err := NewProxy(c, "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/github.com/BurntSushi/toml/@v/list", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected %d but got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestModuleRequestFromContext(t *testing.T) {
+	var got *ModuleRequest
+	c := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       moduleFILE(),
+			}, nil
+		},
+	}
+	p, err := NewProxy(c, "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddListProxy(func(w http.ResponseWriter, r *http.Request, versionList []string) error {
+		mr, ok := ModuleRequestFromContext(r.Context())
+		if !ok {
+			t.Fatal("ModuleRequest is not found in the context")
+		}
+		got = mr
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/github.com/!burnt!sushi/toml/@v/list", nil))
+	want := &ModuleRequest{Path: "github.com/BurntSushi/toml", Kind: KindList}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v but got %+v", want, got)
+	}
+}
+
+func TestModuleRequest_URLPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		mr      *ModuleRequest
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Latest",
+			mr:   &ModuleRequest{Path: "github.com/BurntSushi/toml", Kind: KindLatest},
+			want: "/github.com/!burnt!sushi/toml/@latest",
+		},
+		{
+			name: "List",
+			mr:   &ModuleRequest{Path: "github.com/BurntSushi/toml", Kind: KindList},
+			want: "/github.com/!burnt!sushi/toml/@v/list",
+		},
+		{
+			name: "Info",
+			mr:   &ModuleRequest{Path: "github.com/BurntSushi/toml", Version: "v0.3.1", Kind: KindInfo},
+			want: "/github.com/!burnt!sushi/toml/@v/v0.3.1.info",
+		},
+		{
+			name: "Mod",
+			mr:   &ModuleRequest{Path: "github.com/pkg/errors", Version: "v1.0.0-RC1", Kind: KindMod},
+			want: "/github.com/pkg/errors/@v/v1.0.0-!r!c1.mod",
+		},
+		{
+			name: "Zip",
+			mr:   &ModuleRequest{Path: "github.com/pkg/errors", Version: "v0.8.1", Kind: KindZip},
+			want: "/github.com/pkg/errors/@v/v0.8.1.zip",
+		},
+		{
+			name:    "Invalid path",
+			mr:      &ModuleRequest{Path: "", Kind: KindList},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid version",
+			mr:      &ModuleRequest{Path: "github.com/pkg/errors", Version: "v0.8.1/..", Kind: KindZip},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.mr.URLPath()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ModuleRequest.URLPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s but got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package gopp
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// spoolFile is a temporary file which holds the upstream body.
+// The file is removed on Close.
+type spoolFile struct {
+	*os.File
+}
+
+// newTempSpoolFile makes an empty spoolFile.
+func newTempSpoolFile() (*spoolFile, error) {
+	f, err := ioutil.TempFile("", "gopp-spool-")
+	if err != nil {
+		return nil, err
+	}
+	return &spoolFile{File: f}, nil
+}
+
+// newSpoolFile copies r to a temporary file and rewinds it.
+func newSpoolFile(r io.Reader) (*spoolFile, error) {
+	sf, err := newTempSpoolFile()
+	if err != nil {
+		return nil, err
+	}
+	f := sf.File
+	if _, err := io.Copy(f, r); err != nil {
+		sf.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		sf.Close()
+		return nil, err
+	}
+	return sf, nil
+}
+
+// spool returns body as spoolFile. body is reused when it is already
+// spoolFile. Otherwise it is copied to a temporary file and closed.
+func spool(body io.ReadCloser) (*spoolFile, error) {
+	if sf, ok := body.(*spoolFile); ok {
+		return sf, nil
+	}
+	defer body.Close()
+	return newSpoolFile(body)
+}
+
+func (sf *spoolFile) Close() error {
+	err := sf.File.Close()
+	os.Remove(sf.Name())
+	return err
+}
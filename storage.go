@@ -0,0 +1,227 @@
+package gopp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage represents backend which stores module artifacts.
+// name is slash-separated path which is encoded by the case-encoding
+// like "github.com/!burnt!sushi/toml/@v/v0.3.1.zip".
+type Storage interface {
+	// Get returns the contents of name. The error satisfies
+	// errors.Is(err, os.ErrNotExist) when name is not stored.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// Put stores the contents which are read from r until EOF.
+	// name must not be stored when reading r is failed.
+	Put(ctx context.Context, name string, r io.Reader) error
+	// Stat returns information of name. The error satisfies
+	// errors.Is(err, os.ErrNotExist) when name is not stored.
+	Stat(ctx context.Context, name string) (*StorageInfo, error)
+	// List returns sorted names which have prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// StorageInfo represents information of the stored artifact.
+type StorageInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// FileStorage is Storage which stores artifacts on the local disk.
+// The layout is the same as GOMODCACHE/cache/download.
+type FileStorage struct {
+	dir string
+}
+
+var _ Storage = (*FileStorage)(nil)
+
+// NewFileStorage makes FileStorage which stores artifacts under dir.
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if dir == "" {
+		return nil, errors.New("empty storage directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStorage{dir: dir}, nil
+}
+
+func (s *FileStorage) filename(name string) (string, error) {
+	clean := path.Clean("/" + name)
+	if clean == "/" || clean != "/"+strings.TrimPrefix(name, "/") {
+		return "", &os.PathError{Op: "open", Path: name, Err: os.ErrInvalid}
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+// Get implements Storage.
+func (s *FileStorage) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	filename, err := s.filename(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filename)
+}
+
+// Put implements Storage.
+func (s *FileStorage) Put(ctx context.Context, name string, r io.Reader) error {
+	filename, err := s.filename(name)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after rename
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// Stat implements Storage.
+func (s *FileStorage) Stat(ctx context.Context, name string) (*StorageInfo, error) {
+	filename, err := s.filename(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &StorageInfo{
+		Name:    name,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+	}, nil
+}
+
+// List implements Storage.
+func (s *FileStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(s.dir, func(filename string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || strings.Contains(fi.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, filename)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// MemoryStorage is Storage which stores artifacts in memory.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]*memoryObject
+	now     func() time.Time
+}
+
+type memoryObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+var _ Storage = (*MemoryStorage)(nil)
+
+// NewMemoryStorage makes empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		objects: make(map[string]*memoryObject),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStorage) get(name string) (*memoryObject, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return obj, nil
+}
+
+// Get implements Storage.
+func (s *MemoryStorage) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	obj, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+// Put implements Storage.
+func (s *MemoryStorage) Put(ctx context.Context, name string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[name] = &memoryObject{
+		data:    data,
+		modTime: s.now(),
+	}
+	return nil
+}
+
+// Stat implements Storage.
+func (s *MemoryStorage) Stat(ctx context.Context, name string) (*StorageInfo, error) {
+	obj, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return &StorageInfo{
+		Name:    name,
+		Size:    int64(len(obj.data)),
+		ModTime: obj.modTime,
+	}, nil
+}
+
+// List implements Storage.
+func (s *MemoryStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var names []string
+	for name := range s.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,93 @@
+package gopp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) { return 0, errors.New("error") }
+
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+	const name = "github.com/!burnt!sushi/toml/@v/v0.3.1.mod"
+
+	if _, err := s.Get(ctx, name); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist but got %v", err)
+	}
+	if _, err := s.Stat(ctx, name); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist but got %v", err)
+	}
+
+	want := "module github.com/BurntSushi/toml\n"
+	if err := s.Put(ctx, name, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+	body, err := s.Get(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("expected %q but got %q", want, string(got))
+	}
+	info, err := s.Stat(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(want)) || info.ModTime.IsZero() {
+		t.Errorf("unexpected info %+v", info)
+	}
+
+	// failed Put must not store anything.
+	const failed = "github.com/!burnt!sushi/toml/@v/v0.3.1.zip"
+	if err := s.Put(ctx, failed, io.MultiReader(strings.NewReader("zip"), errReader{})); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+	if _, err := s.Stat(ctx, failed); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist but got %v", err)
+	}
+
+	if err := s.Put(ctx, "github.com/pkg/errors/@v/list", strings.NewReader("v0.8.1\n")); err != nil {
+		t.Fatal(err)
+	}
+	names, err := s.List(ctx, "github.com/!burnt!sushi/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{name}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected %v but got %v", want, names)
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+
+	if _, err := s.Get(context.Background(), "../escape"); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+	if _, err := NewFileStorage(""); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, NewMemoryStorage())
+}
@@ -0,0 +1,84 @@
+package gopp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// AddSumDB registers checksum database which is proxied by
+// /sumdb/<name>/supported, /sumdb/<name>/lookup/... and /sumdb/<name>/tile/...
+// see "Proxying a checksum database" in `go help goproxy`.
+// rawURL is the url of the checksum database. "https://<name>" is used when it is empty.
+func (p *Proxy) AddSumDB(name, rawURL string) error {
+	if name == "" || strings.ContainsAny(name, "/+") {
+		return fmt.Errorf("unexpected checksum database name: %q", name)
+	}
+	if rawURL == "" {
+		rawURL = "https://" + name
+	}
+	u, err := url.ParseRequestURI(rawURL)
+	if err != nil {
+		return fmt.Errorf("unexpected checksum database url: %v", err)
+	}
+	if p.sumdbs == nil {
+		p.sumdbs = make(map[string]*url.URL)
+	}
+	p.sumdbs[name] = u
+	return nil
+}
+
+func isSumDBPath(urlPath string) bool {
+	return strings.HasPrefix(urlPath, "/sumdb/")
+}
+
+// sumdbProxy proxies /sumdb/<name>/... to the checksum database.
+func (p *Proxy) sumdbProxy(w http.ResponseWriter, r *http.Request) error {
+	// /sumdb/sum.golang.org/lookup/golang.org/x/net@v0.0.1
+	elems := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/sumdb/"), "/", 2)
+	if len(elems) != 2 {
+		return &statusError{code: http.StatusNotFound, err: errors.New("unexpected checksum database path")}
+	}
+	name, rest := elems[0], elems[1]
+	u, ok := p.sumdbs[name]
+	if !ok {
+		// the go command connects to the checksum database directly when
+		// the proxy responds 404 or 410 for /supported.
+		return &statusError{code: http.StatusNotFound, err: fmt.Errorf("unsupported checksum database: %s", name)}
+	}
+	if path.Clean("/"+rest) != "/"+rest {
+		return &statusError{code: http.StatusBadRequest, err: fmt.Errorf("unexpected checksum database path: %s", rest)}
+	}
+	switch {
+	case rest == "supported":
+		w.WriteHeader(http.StatusOK)
+		return nil
+	case rest == "latest",
+		strings.HasPrefix(rest, "lookup/"),
+		strings.HasPrefix(rest, "tile/"):
+	default:
+		return &statusError{code: http.StatusNotFound, err: fmt.Errorf("unexpected checksum database path: %s", rest)}
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), "GET", joinURLPath(u, rest).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newUpstreamStatusError(resp)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
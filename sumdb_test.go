@@ -0,0 +1,144 @@
+package gopp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProxy_AddSumDB(t *testing.T) {
+	tests := []struct {
+		name    string
+		dbName  string
+		rawURL  string
+		wantErr bool
+	}{
+		{
+			name:   "Valid",
+			dbName: "sum.golang.org",
+		},
+		{
+			name:   "Valid with url",
+			dbName: "sum.golang.org",
+			rawURL: "https://sum.golang.google.cn",
+		},
+		{
+			name:    "Invalid name",
+			dbName:  "sum.golang.org+033de0ae",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid url",
+			dbName:  "sum.golang.org",
+			rawURL:  "sum.golang.org",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Proxy{}
+			if err := p.AddSumDB(tt.dbName, tt.rawURL); (err != nil) != tt.wantErr {
+				t.Errorf("Proxy.AddSumDB() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProxy_sumdbProxy(t *testing.T) {
+	tests := []struct {
+		name        string
+		urlPath     string
+		upstreamURL string
+		wantCode    int
+		wantBody    string
+	}{
+		{
+			name:     "Supported",
+			urlPath:  "/sumdb/sum.golang.org/supported",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Unsupported",
+			urlPath:  "/sumdb/sum.example.com/supported",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:        "Lookup",
+			urlPath:     "/sumdb/sum.golang.org/lookup/github.com/!burnt!sushi/toml@v0.3.1",
+			upstreamURL: "https://sum.golang.org/lookup/github.com/!burnt!sushi/toml@v0.3.1",
+			wantCode:    http.StatusOK,
+			wantBody:    "response",
+		},
+		{
+			name:        "Tile",
+			urlPath:     "/sumdb/sum.golang.org/tile/8/0/001",
+			upstreamURL: "https://sum.golang.org/tile/8/0/001",
+			wantCode:    http.StatusOK,
+			wantBody:    "response",
+		},
+		{
+			name:        "Latest",
+			urlPath:     "/sumdb/sum.golang.org/latest",
+			upstreamURL: "https://sum.golang.org/latest",
+			wantCode:    http.StatusOK,
+			wantBody:    "response",
+		},
+		{
+			name:        "Upstream not found",
+			urlPath:     "/sumdb/sum.golang.org/lookup/example.com/notfound@v0.0.1",
+			upstreamURL: "https://sum.golang.org/lookup/example.com/notfound@v0.0.1",
+			wantCode:    http.StatusNotFound,
+			wantBody:    "not found",
+		},
+		{
+			name:     "Unknown endpoint",
+			urlPath:  "/sumdb/sum.golang.org/unknown",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Traversal",
+			urlPath:  "/sumdb/sum.golang.org/tile/../../secret",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &mockClient{
+				DoMock: func(req *http.Request) (*http.Response, error) {
+					if got := req.URL.String(); got != tt.upstreamURL {
+						t.Errorf("expected %s but got %s", tt.upstreamURL, got)
+					}
+					if strings.Contains(req.URL.Path, "notfound") {
+						return &http.Response{
+							StatusCode: http.StatusNotFound,
+							Body:       ioutil.NopCloser(strings.NewReader("not found")),
+						}, nil
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(strings.NewReader("response")),
+					}, nil
+				},
+			}
+			p, err := NewProxy(c, "https://localhost")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddSumDB("sum.golang.org", ""); err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest("GET", "/", nil)
+			req.URL.Path = tt.urlPath
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected %d but got %d", tt.wantCode, rec.Code)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("expected %q but got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
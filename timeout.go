@@ -0,0 +1,26 @@
+package gopp
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SetTimeout sets timeout of the requests for the endpoint of kind.
+// The timeout covers both the upstream request and streaming the body to the
+// client, so e.g. /@v/list and .zip can have different deadlines.
+// The timeout of KindZip is also used for .ziphash.
+// Checksum lookups of the Verifier are not covered by the timeout.
+func (p *Proxy) SetTimeout(kind Kind, d time.Duration) error {
+	if kind < KindLatest || kind > KindZip {
+		return fmt.Errorf("unexpected kind: %d", kind)
+	}
+	if d <= 0 {
+		return errors.New("timeout must be positive")
+	}
+	if p.timeouts == nil {
+		p.timeouts = make(map[Kind]time.Duration)
+	}
+	p.timeouts[kind] = d
+	return nil
+}
@@ -0,0 +1,128 @@
+package gopp
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxy_SetTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    Kind
+		d       time.Duration
+		wantErr bool
+	}{
+		{
+			name: "Valid",
+			kind: KindZip,
+			d:    time.Minute,
+		},
+		{
+			name:    "Invalid kind",
+			kind:    Kind(0),
+			d:       time.Minute,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid duration",
+			kind:    KindList,
+			d:       0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Proxy{}
+			if err := p.SetTimeout(tt.kind, tt.d); (err != nil) != tt.wantErr {
+				t.Errorf("Proxy.SetTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// blockingClient blocks until the context of the request is done.
+func blockingClient() *mockClient {
+	return &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		},
+	}
+}
+
+func TestProxy_timeout(t *testing.T) {
+	p, err := NewProxy(blockingClient(), "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetTimeout(KindList, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if rec := serve(p, "/github.com/pkg/errors/@v/list"); rec.Code != http.StatusInternalServerError {
+			t.Errorf("expected %d but got %d", http.StatusInternalServerError, rec.Code)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout is not applied")
+	}
+}
+
+func TestProxy_cancel(t *testing.T) {
+	p, err := NewProxy(blockingClient(), "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "GET", "/github.com/pkg/errors/@v/v0.0.1.zip", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.handlers(nil, req) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("expected %v but got %v", context.Canceled, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancel is not propagated")
+	}
+}
+
+func TestProxy_timeoutCoversBody(t *testing.T) {
+	c := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader("zip")),
+			}, nil
+		},
+	}
+	p, err := NewProxy(c, "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetTimeout(KindZip, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddZipProxyHandler(func(w http.ResponseWriter, r *http.Request, body io.Reader) error {
+		<-r.Context().Done()
+		return r.Context().Err()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve(p, "/github.com/pkg/errors/@v/v0.0.1.zip"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d but got %d", http.StatusInternalServerError, rec.Code)
+	}
+}
@@ -0,0 +1,132 @@
+package gopp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// upstream is an element of the GOPROXY list.
+type upstream struct {
+	u      *url.URL // nil when direct or off
+	direct bool
+	off    bool
+
+	// client serves the element instead of the proxy client. It is set for "file://".
+	client ProxyClient
+
+	// fallBackOnError reports whether the next element should be tried on any error.
+	// It is true when the element is followed by "|". Otherwise ("," separated)
+	// the next element is tried only on 404 and 410.
+	fallBackOnError bool
+}
+
+func (u *upstream) String() string {
+	switch {
+	case u.direct:
+		return "direct"
+	case u.off:
+		return "off"
+	}
+	if u.u.User == nil {
+		return u.u.String()
+	}
+	// the password must not be logged.
+	redacted := *u.u
+	if _, ok := redacted.User.Password(); ok {
+		redacted.User = url.UserPassword(redacted.User.Username(), "xxxxx")
+	}
+	return redacted.String()
+}
+
+// formatGoProxy formats upstreams as GOPROXY list in which passwords are redacted.
+func formatGoProxy(upstreams []*upstream) string {
+	var b strings.Builder
+	for i, up := range upstreams {
+		if i > 0 {
+			if upstreams[i-1].fallBackOnError {
+				b.WriteByte('|')
+			} else {
+				b.WriteByte(',')
+			}
+		}
+		b.WriteString(up.String())
+	}
+	return b.String()
+}
+
+// parseGoProxy parses GOPROXY list like "https://a,https://b|file:///c,direct,off".
+// see `go help goproxy`
+func parseGoProxy(goproxy string) ([]*upstream, error) {
+	var upstreams []*upstream
+	for goproxy != "" {
+		var elem string
+		fallBackOnError := false
+		if i := strings.IndexAny(goproxy, ",|"); i >= 0 {
+			elem = goproxy[:i]
+			fallBackOnError = goproxy[i] == '|'
+			goproxy = goproxy[i+1:]
+		} else {
+			elem = goproxy
+			goproxy = ""
+		}
+		elem = strings.TrimSpace(elem)
+		if elem == "" {
+			continue
+		}
+		up := &upstream{fallBackOnError: fallBackOnError}
+		switch elem {
+		case "direct":
+			up.direct = true
+		case "off":
+			up.off = true
+		default:
+			// we expected `elem == "https://original-goproxy.host"`
+			u, err := url.ParseRequestURI(elem)
+			if err != nil {
+				return nil, err
+			}
+			switch u.Scheme {
+			case "http", "https":
+			case "file":
+				up.client = &DirClient{dir: filepath.FromSlash(u.Path)}
+			default:
+				return nil, fmt.Errorf("unsupported scheme %q in %s", u.Scheme, elem)
+			}
+			up.u = u
+		}
+		upstreams = append(upstreams, up)
+	}
+	if len(upstreams) == 0 {
+		return nil, errors.New("empty GOPROXY list")
+	}
+	// the last element can not fall back to anything.
+	upstreams[len(upstreams)-1].fallBackOnError = false
+	return upstreams, nil
+}
+
+// joinURLPath returns clone of u which url path is joined with p.
+// "!" of the case-encoding is kept as it is.
+func joinURLPath(u *url.URL, p string) *url.URL {
+	ret := *u // clone
+	ret.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(p, "/")
+	ret.RawPath = ""
+	if u.RawPath == "" {
+		ret.RawPath = ret.Path
+	}
+	return &ret
+}
+
+var (
+	errDirectNotSupported = &statusError{
+		code: http.StatusNotImplemented,
+		err:  errors.New("direct mode is not supported"),
+	}
+	errGoProxyOff = &statusError{
+		code: http.StatusForbidden,
+		err:  errors.New("module lookup disabled by GOPROXY=off"),
+	}
+)
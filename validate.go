@@ -0,0 +1,264 @@
+package gopp
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	modzip "golang.org/x/mod/zip"
+)
+
+const (
+	// maxInfoSize is the maximum size of .info and /@latest to validate.
+	maxInfoSize = 1 << 20
+	// maxGoModSize is the maximum size of .mod to validate like the go command.
+	maxGoModSize = 16 << 20
+)
+
+// ValidationError represents that the upstream response is malformed or
+// inconsistent with the request.
+type ValidationError struct {
+	Path    string
+	Version string // requested version. empty for /@latest
+	Kind    Kind
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	target := e.Path
+	if e.Version != "" {
+		target += "@" + e.Version
+	}
+	return fmt.Sprintf("%s: invalid %s response from upstream: %v", target, e.Kind, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// StatusCode returns HTTP status code to respond.
+func (e *ValidationError) StatusCode() int { return http.StatusBadGateway }
+
+// EnableValidation enables validation of the upstream responses for kinds
+// before they are cached and served. *ValidationError is returned for the
+// invalid response.
+//
+// KindLatest and KindInfo: the version must be valid, canonical semantic version
+// and match the requested version when it is canonical. The time must be set.
+// @latest which is resolved from @v/list or PinLatest is also validated.
+//
+// KindMod: go.mod must be parsed by golang.org/x/mod/modfile and the module
+// directive must match the requested module path including the major version suffix.
+//
+// KindZip: the zip must satisfy the constraints of golang.org/x/mod/zip.
+// e.g. every file is under "module@version/", no file names collide
+// case-insensitively, no files are too large, no files are symlinks and no
+// files are in vendor directories or nested modules.
+// The zip is spooled to a temporary file to be checked.
+func (p *Proxy) EnableValidation(kinds ...Kind) error {
+	for _, kind := range kinds {
+		switch kind {
+		case KindLatest, KindInfo, KindMod, KindZip:
+		default:
+			return fmt.Errorf("unsupported kind to validate: %s", kind)
+		}
+	}
+	if p.validations == nil {
+		p.validations = make(map[Kind]bool)
+	}
+	for _, kind := range kinds {
+		p.validations[kind] = true
+	}
+	return nil
+}
+
+// validate validates body of mr. body is closed by validate and returned body
+// should be used instead of it.
+func (p *Proxy) validate(mr *ModuleRequest, body io.ReadCloser) (io.ReadCloser, error) {
+	if mr.Kind == KindZip {
+		return p.validateZip(mr, body)
+	}
+	defer body.Close()
+	switch mr.Kind {
+	case KindLatest, KindInfo:
+		data, err := ioutil.ReadAll(io.LimitReader(body, maxInfoSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxInfoSize {
+			return nil, p.validationError(mr, errors.New("too large"))
+		}
+		if err := validateInfo(mr, data); err != nil {
+			return nil, p.validationError(mr, err)
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	case KindMod:
+		data, err := ioutil.ReadAll(io.LimitReader(body, maxGoModSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxGoModSize {
+			return nil, p.validationError(mr, errors.New("too large"))
+		}
+		if err := validateGoMod(mr, data); err != nil {
+			return nil, p.validationError(mr, err)
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	return nil, fmt.Errorf("unexpected kind to validate: %s", mr.Kind)
+}
+
+func (p *Proxy) validationError(mr *ModuleRequest, err error) error {
+	return &ValidationError{Path: mr.Path, Version: mr.Version, Kind: mr.Kind, Err: err}
+}
+
+func validateInfo(mr *ModuleRequest, data []byte) error {
+	info, err := body2VersionInfo(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return checkInfo(mr, info)
+}
+
+// checkInfo checks info of mr which is KindLatest or KindInfo.
+func checkInfo(mr *ModuleRequest, info *Info) error {
+	if !semver.IsValid(info.Version) {
+		return fmt.Errorf("invalid version %q", info.Version)
+	}
+	if !isCanonicalVersion(info.Version) {
+		return fmt.Errorf("version %q is not canonical", info.Version)
+	}
+	// the go command requests non-canonical versions like v1.2 and the
+	// upstream responds the canonical version like v1.2.0.
+	if mr.Kind == KindInfo && isCanonicalVersion(mr.Version) && info.Version != mr.Version {
+		return fmt.Errorf("version %q does not match", info.Version)
+	}
+	if info.Time.IsZero() {
+		return errors.New("time is not set")
+	}
+	return nil
+}
+
+// isCanonicalVersion reports whether v is the canonical semantic version
+// which may have +incompatible suffix.
+func isCanonicalVersion(v string) bool {
+	c := semver.Canonical(v)
+	return c == v || c+"+incompatible" == v
+}
+
+// validateZip validates body of the zip. body is closed by validateZip and
+// returned body should be used instead of it.
+func (p *Proxy) validateZip(mr *ModuleRequest, body io.ReadCloser) (io.ReadCloser, error) {
+	sf, err := spool(body)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkZip(mr, sf.Name()); err != nil {
+		sf.Close()
+		return nil, p.validationError(mr, err)
+	}
+	if _, err := sf.Seek(0, io.SeekStart); err != nil {
+		sf.Close()
+		return nil, err
+	}
+	return sf, nil
+}
+
+// checkZip checks the zip file by modzip.CheckZip, which also rejects go.mod
+// of nested modules. In addition, files of vendored packages and files which
+// are not regular files like symlinks are rejected.
+func checkZip(mr *ModuleRequest, zipFile string) error {
+	m := module.Version{Path: mr.Path, Version: mr.Version}
+	if _, err := modzip.CheckZip(m, zipFile); err != nil {
+		return err
+	}
+	zr, err := zip.OpenReader(zipFile)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	prefix := m.Path + "@" + m.Version + "/"
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			// directory entries are allowed by modzip.CheckZip.
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return fmt.Errorf("%s: not a regular file", f.Name)
+		}
+		if isVendoredPackage(strings.TrimPrefix(f.Name, prefix)) {
+			return fmt.Errorf("%s: file is in vendor directory", f.Name)
+		}
+	}
+	return nil
+}
+
+// isVendoredPackage reports whether name is a file of the vendored package
+// which is omitted by modzip.CreateFromDir. It keeps the same rule as golang.org/x/mod/zip.
+func isVendoredPackage(name string) bool {
+	var i int
+	if strings.HasPrefix(name, "vendor/") {
+		i += len("vendor/")
+	} else if j := strings.Index(name, "/vendor/"); j >= 0 {
+		// the index is not added in golang.org/x/mod/zip for compatibility.
+		i += len("/vendor/")
+	} else {
+		return false
+	}
+	return strings.Contains(name[i:], "/")
+}
+
+func validateGoMod(mr *ModuleRequest, data []byte) error {
+	var modPath string
+	f, err := modfile.ParseLax("go.mod", data, nil)
+	switch {
+	case err == nil:
+		if f.Module != nil {
+			modPath = f.Module.Mod.Path
+		}
+	case onlyGoVersionErrors(data, err):
+		// the format of the go version has been changed by newer go commands
+		// like "go 1.21.0". it is checked by the go command itself.
+		modPath = modfile.ModulePath(data)
+	default:
+		return err
+	}
+	if modPath == "" {
+		return errors.New("no module directive")
+	}
+	if modPath != mr.Path {
+		return fmt.Errorf("module path %q does not match", modPath)
+	}
+	_, pathMajor, ok := module.SplitPathVersion(modPath)
+	if !ok {
+		return fmt.Errorf("invalid module path %q", modPath)
+	}
+	return module.CheckPathMajor(mr.Version, pathMajor)
+}
+
+// onlyGoVersionErrors reports whether all errors of modfile.ParseLax are
+// reported for the go directive of data.
+func onlyGoVersionErrors(data []byte, err error) bool {
+	var errs modfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) == 0 {
+		return false
+	}
+	lines := strings.Split(string(data), "\n")
+	for _, e := range errs {
+		n := e.Pos.Line - 1
+		if n < 0 || n >= len(lines) {
+			return false
+		}
+		fields := strings.Fields(lines[n])
+		if len(fields) == 0 || fields[0] != "go" {
+			return false
+		}
+	}
+	return true
+}
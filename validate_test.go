@@ -0,0 +1,401 @@
+package gopp
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	modzip "golang.org/x/mod/zip"
+)
+
+func TestProxy_validateInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Valid info",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.1.info",
+			body:     `{"Version":"v0.0.1","Time":"2019-01-02T22:52:24-08:00"}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Valid incompatible info",
+			urlPath:  "/github.com/pkg/errors/@v/v2.0.0+incompatible.info",
+			body:     `{"Version":"v2.0.0+incompatible","Time":"2019-01-02T22:52:24-08:00"}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Valid non-canonical request",
+			urlPath:  "/github.com/pkg/errors/@v/v1.2.info",
+			body:     `{"Version":"v1.2.0","Time":"2019-01-02T22:52:24-08:00"}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Non-canonical response",
+			urlPath:  "/github.com/pkg/errors/@v/v1.2.info",
+			body:     `{"Version":"v1.2","Time":"2019-01-02T22:52:24-08:00"}`,
+			wantCode: http.StatusBadGateway,
+			wantErr:  `version "v1.2" is not canonical`,
+		},
+		{
+			name:     "Version mismatch",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.1.info",
+			body:     `{"Version":"v0.0.2","Time":"2019-01-02T22:52:24-08:00"}`,
+			wantCode: http.StatusBadGateway,
+			wantErr:  `github.com/pkg/errors@v0.0.1: invalid info response from upstream: version "v0.0.2" does not match`,
+		},
+		{
+			name:     "Zero time",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.1.info",
+			body:     `{"Version":"v0.0.1"}`,
+			wantCode: http.StatusBadGateway,
+			wantErr:  "time is not set",
+		},
+		{
+			name:     "Broken json",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.1.info",
+			body:     `{"Version":`,
+			wantCode: http.StatusBadGateway,
+		},
+		{
+			name:     "Too large",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.1.info",
+			body:     `{"Version":"v0.0.1","Time":"2019-01-02T22:52:24-08:00","X":"` + strings.Repeat("x", maxInfoSize) + `"}`,
+			wantCode: http.StatusBadGateway,
+			wantErr:  "too large",
+		},
+		{
+			name:     "Valid latest",
+			urlPath:  "/github.com/pkg/errors/@latest",
+			body:     `{"Version":"v0.0.3","Time":"2019-01-02T22:52:24-08:00"}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Non-canonical latest",
+			urlPath:  "/github.com/pkg/errors/@latest",
+			body:     `{"Version":"v1.2","Time":"2019-01-02T22:52:24-08:00"}`,
+			wantCode: http.StatusBadGateway,
+			wantErr:  `github.com/pkg/errors: invalid latest response from upstream: version "v1.2" is not canonical`,
+		},
+		{
+			name:     "Invalid latest",
+			urlPath:  "/github.com/pkg/errors/@latest",
+			body:     `{"Version":"master","Time":"2019-01-02T22:52:24-08:00"}`,
+			wantCode: http.StatusBadGateway,
+			wantErr:  `invalid version "master"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, cache, _ := newCacheTestProxy(t, NewMemoryStorage(), time.Minute, http.StatusOK, tt.body)
+			if err := p.EnableValidation(KindLatest, KindInfo); err != nil {
+				t.Fatal(err)
+			}
+			var gotErr error
+			if err := p.AddErrHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+				gotErr = err
+				defaultErrHandler()(w, r, err)
+			}); err != nil {
+				t.Fatal(err)
+			}
+			rec := serve(p, tt.urlPath)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d but got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusOK {
+				return
+			}
+			var ve *ValidationError
+			if !errors.As(gotErr, &ve) {
+				t.Fatalf("expected *ValidationError but got %#v", gotErr)
+			}
+			if tt.wantErr != "" && !strings.Contains(ve.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q but got %q", tt.wantErr, ve.Error())
+			}
+			// invalid response must not be cached.
+			mr, err := parseModuleRequest(tt.urlPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body, ok := cache.get(context.Background(), mr); ok {
+				body.Close()
+				t.Error("invalid response is cached")
+			}
+		})
+	}
+}
+
+func TestProxy_EnableValidation(t *testing.T) {
+	p := &Proxy{}
+	if err := p.EnableValidation(KindList); err == nil {
+		t.Error("expected error for KindList")
+	}
+	if err := p.EnableValidation(KindInfo); err != nil {
+		t.Fatal(err)
+	}
+	if !p.validations[KindInfo] || p.validations[KindLatest] {
+		t.Errorf("unexpected validations: %v", p.validations)
+	}
+}
+
+func TestProxy_validateGoMod(t *testing.T) {
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Valid",
+			urlPath:  "/github.com/pkg/errors/@v/v0.9.1.mod",
+			body:     "module github.com/pkg/errors\n\ngo 1.13\n\nrequire golang.org/x/mod v0.4.2\n",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Valid major version",
+			urlPath:  "/github.com/go-yaml/yaml/v3/@v/v3.0.1.mod",
+			body:     "module github.com/go-yaml/yaml/v3\n",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Valid gopkg.in",
+			urlPath:  "/gopkg.in/yaml.v2/@v/v2.4.0.mod",
+			body:     "module gopkg.in/yaml.v2\n",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Valid incompatible",
+			urlPath:  "/github.com/go-yaml/yaml/@v/v2.0.0+incompatible.mod",
+			body:     "module github.com/go-yaml/yaml\n",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Valid with newer go and toolchain directive",
+			urlPath:  "/github.com/pkg/errors/@v/v0.9.1.mod",
+			body:     "module github.com/pkg/errors\n\ngo 1.21.0\n\ntoolchain go1.22.1\n",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Module path mismatch",
+			urlPath:  "/github.com/pkg/errors/@v/v0.9.1.mod",
+			body:     "module github.com/evil/errors\n",
+			wantCode: http.StatusBadGateway,
+			wantErr:  `github.com/pkg/errors@v0.9.1: invalid mod response from upstream: module path "github.com/evil/errors" does not match`,
+		},
+		{
+			name:     "Major version mismatch",
+			urlPath:  "/github.com/go-yaml/yaml/@v/v2.0.0.mod",
+			body:     "module github.com/go-yaml/yaml\n",
+			wantCode: http.StatusBadGateway,
+			wantErr:  "should be v0 or v1, not v2",
+		},
+		{
+			name:     "No module directive",
+			urlPath:  "/github.com/pkg/errors/@v/v0.9.1.mod",
+			body:     "go 1.13\n",
+			wantCode: http.StatusBadGateway,
+			wantErr:  "no module directive",
+		},
+		{
+			name:     "Malformed",
+			urlPath:  "/github.com/pkg/errors/@v/v0.9.1.mod",
+			body:     "module github.com/pkg/errors\nrequire (\n",
+			wantCode: http.StatusBadGateway,
+		},
+		{
+			name:     "Malformed with newer go directive",
+			urlPath:  "/github.com/pkg/errors/@v/v0.9.1.mod",
+			body:     "module github.com/pkg/errors\ngo 1.21.0\nrequire golang.org/x/mod\n",
+			wantCode: http.StatusBadGateway,
+			wantErr:  "usage: require",
+		},
+		{
+			name:     "HTML",
+			urlPath:  "/github.com/pkg/errors/@v/v0.9.1.mod",
+			body:     "<html><body>login</body></html>\n",
+			wantCode: http.StatusBadGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _, _ := newCacheTestProxy(t, NewMemoryStorage(), 0, http.StatusOK, tt.body)
+			if err := p.EnableValidation(KindMod); err != nil {
+				t.Fatal(err)
+			}
+			rec := serve(p, tt.urlPath)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d but got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusOK && rec.Body.String() != tt.body {
+				t.Errorf("expected %q but got %q", tt.body, rec.Body.String())
+			}
+			if tt.wantErr != "" && !strings.Contains(rec.Body.String(), tt.wantErr) {
+				t.Errorf("expected error containing %q but got %q", tt.wantErr, rec.Body.String())
+			}
+		})
+	}
+}
+
+func makeTestSymlinkZip(t *testing.T, name, target string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fh := &zip.FileHeader{Name: name}
+	fh.SetMode(os.ModeSymlink | 0777)
+	w, err := zw.CreateHeader(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, target); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProxy_validateZip(t *testing.T) {
+	const prefix = "github.com/pkg/errors@v0.9.1/"
+	tests := []struct {
+		name     string
+		body     []byte
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name: "Valid",
+			body: makeTestZip(t, map[string]string{
+				prefix + "go.mod":    "module github.com/pkg/errors\n",
+				prefix + "errors.go": "package errors\n",
+			}),
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "Valid with directory entries",
+			body: makeTestZip(t, map[string]string{
+				prefix:                   "",
+				prefix + "internal/":     "",
+				prefix + "go.mod":        "module github.com/pkg/errors\n",
+				prefix + "internal/x.go": "package internal\n",
+			}),
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "Outside of the prefix",
+			body: makeTestZip(t, map[string]string{
+				prefix + "errors.go":                "package errors\n",
+				"github.com/pkg/errors@v0.9.2/x.go": "package errors\n",
+			}),
+			wantCode: http.StatusBadGateway,
+			wantErr:  `path does not have prefix "github.com/pkg/errors@v0.9.1/"`,
+		},
+		{
+			name: "Path traversal",
+			body: makeTestZip(t, map[string]string{
+				prefix + "../../../../evil.go": "package evil\n",
+			}),
+			wantCode: http.StatusBadGateway,
+		},
+		{
+			name: "Case-insensitive collision",
+			body: makeTestZip(t, map[string]string{
+				prefix + "errors.go": "package errors\n",
+				prefix + "ERRORS.go": "package errors\n",
+			}),
+			wantCode: http.StatusBadGateway,
+			wantErr:  "case-insensitive file name collision",
+		},
+		{
+			name: "Vendored package",
+			body: makeTestZip(t, map[string]string{
+				prefix + "errors.go":           "package errors\n",
+				prefix + "vendor/evil/evil.go": "package evil\n",
+			}),
+			wantCode: http.StatusBadGateway,
+			wantErr:  "vendor/evil/evil.go",
+		},
+		{
+			name: "Nested module",
+			body: makeTestZip(t, map[string]string{
+				prefix + "errors.go":     "package errors\n",
+				prefix + "sub/go.mod":    "module github.com/pkg/errors/sub\n",
+				prefix + "sub/errors.go": "package sub\n",
+			}),
+			wantCode: http.StatusBadGateway,
+			wantErr:  "go.mod file not in module root directory",
+		},
+		{
+			name:     "Symlink",
+			body:     makeTestSymlinkZip(t, prefix+"link.go", "/etc/passwd"),
+			wantCode: http.StatusBadGateway,
+			wantErr:  "not a regular file",
+		},
+		{
+			name: "Too large go.mod",
+			body: makeTestZip(t, map[string]string{
+				prefix + "go.mod": "module github.com/pkg/errors\n" + strings.Repeat("\n", modzip.MaxGoMod),
+			}),
+			wantCode: http.StatusBadGateway,
+		},
+		{
+			name:     "Not a zip",
+			body:     []byte("<html></html>"),
+			wantCode: http.StatusBadGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _, _ := newCacheTestProxy(t, NewMemoryStorage(), 0, http.StatusOK, string(tt.body))
+			if err := p.EnableValidation(KindZip); err != nil {
+				t.Fatal(err)
+			}
+			rec := serve(p, "/github.com/pkg/errors/@v/v0.9.1.zip")
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d but got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusOK && !bytes.Equal(rec.Body.Bytes(), tt.body) {
+				t.Error("unexpected zip is served")
+			}
+			if tt.wantErr != "" && !strings.Contains(rec.Body.String(), tt.wantErr) {
+				t.Errorf("expected error containing %q but got %q", tt.wantErr, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestProxy_validateZipAndVerify(t *testing.T) {
+	data := makeTestZip(t, map[string]string{
+		"github.com/pkg/errors@v0.9.1/errors.go": "package errors\n",
+	})
+	gs, err := ParseGoSum(strings.NewReader("github.com/pkg/errors v0.9.1 " + testZipHash(t, data) + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _, _ := newCacheTestProxy(t, NewMemoryStorage(), 0, http.StatusOK, string(data))
+	if err := p.EnableValidation(KindZip); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddVerifier(NewVerifier(gs)); err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(p, "/github.com/pkg/errors/@v/v0.9.1.zip")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Error("unexpected zip is served")
+	}
+}
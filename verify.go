@@ -0,0 +1,314 @@
+package gopp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// SumGolangOrgKey is the verifier key of sum.golang.org.
+const SumGolangOrgKey = "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ho18cFwC4dtIzDtH"
+
+// ChecksumSource returns go.sum lines of the module version.
+// version may end in "/go.mod" to lookup the hash of go.mod.
+// *sumdb.Client and *GoSum are satisfied this interface.
+type ChecksumSource interface {
+	Lookup(path, version string) (lines []string, err error)
+}
+
+// ChecksumError represents that the downloaded .mod or .zip could not be verified.
+type ChecksumError struct {
+	Path    string
+	Version string // has "/go.mod" suffix for .mod
+	Got     string // h1: hash of the downloaded file
+	Want    []string
+	Err     error // set when the checksum could not be looked up
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("verifying %s@%s: %v", e.Path, e.Version, e.Err)
+	}
+	return fmt.Sprintf("verifying %s@%s: checksum mismatch\n\tdownloaded: %s\n\texpected: %s",
+		e.Path, e.Version, e.Got, strings.Join(e.Want, ", "))
+}
+
+func (e *ChecksumError) Unwrap() error { return e.Err }
+
+// StatusCode returns HTTP status code to respond.
+func (e *ChecksumError) StatusCode() int { return http.StatusForbidden }
+
+// Verifier verifies .mod and .zip of the upstream against the checksums
+// before they are served. The lookups of ChecksumSource are not tied to the
+// context of the incoming request since the interface does not take it.
+type Verifier struct {
+	source  ChecksumSource
+	exclude []string
+}
+
+// NewVerifier makes Verifier which verifies checksums by s.
+func NewVerifier(s ChecksumSource) *Verifier {
+	return &Verifier{source: s}
+}
+
+// Exclude excludes modules which match patterns from verification like
+// GONOSUMDB. patterns are comma-separated glob patterns of module path
+// prefixes. e.g. "*.corp.example.com,rsc.io/private"
+// Exclude can be called multiple times.
+func (v *Verifier) Exclude(patterns string) error {
+	if strings.TrimSpace(patterns) == "" {
+		return errors.New("empty patterns")
+	}
+	v.exclude = append(v.exclude, patterns)
+	return nil
+}
+
+func (v *Verifier) excluded(modPath string) bool {
+	for _, patterns := range v.exclude {
+		if module.MatchPrefixPatterns(patterns, modPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddVerifier registers verifier of .mod and .zip.
+func (p *Proxy) AddVerifier(v *Verifier) error {
+	if v == nil || v.source == nil {
+		return errors.New("unexpected nil")
+	}
+	p.verifier = v
+	return nil
+}
+
+// verify verifies body of mr. body is closed by verify and returned body
+// should be used instead of it.
+func (v *Verifier) verify(mr *ModuleRequest, body io.ReadCloser) (io.ReadCloser, error) {
+	if v.excluded(mr.Path) {
+		return body, nil
+	}
+	switch mr.Kind {
+	case KindMod:
+		defer body.Close()
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		got, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := v.check(mr.Path, mr.Version+"/go.mod", got); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	case KindZip:
+		sf, err := spool(body)
+		if err != nil {
+			return nil, err
+		}
+		got, err := dirhash.HashZip(sf.Name(), dirhash.Hash1)
+		if err != nil {
+			sf.Close()
+			return nil, err
+		}
+		if err := v.check(mr.Path, mr.Version, got); err != nil {
+			sf.Close()
+			return nil, err
+		}
+		return sf, nil
+	}
+	body.Close()
+	return nil, fmt.Errorf("unexpected kind to verify: %s", mr.Kind)
+}
+
+func (v *Verifier) check(path, version, got string) error {
+	lines, err := v.source.Lookup(path, version)
+	if err != nil {
+		return &ChecksumError{Path: path, Version: version, Err: err}
+	}
+	prefix := path + " " + version + " h1:"
+	var want []string
+	for _, line := range lines {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		h := strings.TrimPrefix(line, path+" "+version+" ")
+		if h == got {
+			return nil
+		}
+		want = append(want, h)
+	}
+	if len(want) == 0 {
+		return &ChecksumError{Path: path, Version: version, Err: errors.New("missing h1: checksum")}
+	}
+	return &ChecksumError{Path: path, Version: version, Got: got, Want: want}
+}
+
+// GoSum is ChecksumSource which is parsed from trusted go.sum.
+type GoSum struct {
+	lines map[string][]string // "path version" -> lines
+}
+
+var _ ChecksumSource = (*GoSum)(nil)
+
+// ParseGoSum parses go.sum.
+func ParseGoSum(r io.Reader) (*GoSum, error) {
+	gs := &GoSum{lines: make(map[string][]string)}
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) != 3 {
+			return nil, fmt.Errorf("go.sum:%d: malformed line: %q", lineno, line)
+		}
+		key := f[0] + " " + f[1]
+		gs.lines[key] = append(gs.lines[key], strings.Join(f, " "))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return gs, nil
+}
+
+// Lookup implements ChecksumSource.
+func (gs *GoSum) Lookup(path, version string) ([]string, error) {
+	lines, ok := gs.lines[path+" "+version]
+	if !ok {
+		return nil, fmt.Errorf("missing go.sum entry for %s@%s", path, version)
+	}
+	return lines, nil
+}
+
+// sumdbTimeout is the timeout of each request to the checksum database.
+const sumdbTimeout = 30 * time.Second
+
+// NewSumDBClient makes client of the checksum database which is requested by c.
+// key is the verifier key like SumGolangOrgKey. rawURL is the url of the
+// checksum database. "https://<name of the key>" is used when it is empty.
+//
+// sumdb.Client does not accept context, so the lookups are not canceled with
+// the incoming request and SetTimeout does not apply to them. Instead, each
+// request to the checksum database times out after 30 seconds.
+func NewSumDBClient(c ProxyClient, key, rawURL string) (*sumdb.Client, error) {
+	name := key
+	if i := strings.Index(name, "+"); i >= 0 {
+		name = name[:i]
+	}
+	if rawURL == "" {
+		rawURL = "https://" + name
+	}
+	u, err := url.ParseRequestURI(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected checksum database url: %v", err)
+	}
+	ops := &sumdbOps{
+		client:  c,
+		u:       u,
+		key:     key,
+		name:    name,
+		timeout: sumdbTimeout,
+		cache:   make(map[string][]byte),
+	}
+	return sumdb.NewClient(ops), nil
+}
+
+// sumdbOps implements sumdb.ClientOps. The latest signed tree and fetched
+// records and tiles are kept in memory.
+type sumdbOps struct {
+	client  ProxyClient
+	u       *url.URL
+	key     string
+	name    string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	latest []byte
+	cache  map[string][]byte
+}
+
+func (o *sumdbOps) ReadRemote(path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", joinURLPath(o.u, path).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newUpstreamStatusError(resp)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (o *sumdbOps) ReadConfig(file string) ([]byte, error) {
+	if file == "key" {
+		return []byte(o.key), nil
+	}
+	if file == o.name+"/latest" {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return o.latest, nil
+	}
+	return nil, fmt.Errorf("unknown config %s", file)
+}
+
+func (o *sumdbOps) WriteConfig(file string, old, new []byte) error {
+	if file != o.name+"/latest" {
+		return fmt.Errorf("unknown config %s", file)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !bytes.Equal(old, o.latest) {
+		return sumdb.ErrWriteConflict
+	}
+	o.latest = new
+	return nil
+}
+
+func (o *sumdbOps) ReadCache(file string) ([]byte, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	data, ok := o.cache[file]
+	if !ok {
+		return nil, fmt.Errorf("%s is not cached", file)
+	}
+	return data, nil
+}
+
+func (o *sumdbOps) WriteCache(file string, data []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.cache[file] = data
+}
+
+func (o *sumdbOps) Log(msg string) {}
+
+func (o *sumdbOps) SecurityError(msg string) {
+	log.Printf("gopp: %s", msg)
+}
@@ -0,0 +1,260 @@
+package gopp
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/mod/sumdb/note"
+)
+
+const testModFile = "module github.com/pkg/errors\n"
+
+func makeTestZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testZipHash(t *testing.T, data []byte) string {
+	t.Helper()
+	sf, err := newSpoolFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close()
+	h, err := dirhash.HashZip(sf.Name(), dirhash.Hash1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func testModHash(t *testing.T, data string) string {
+	t.Helper()
+	h, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(data)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestParseGoSum(t *testing.T) {
+	gs, err := ParseGoSum(strings.NewReader(`
+github.com/pkg/errors v0.8.1 h1:iURUrRGxPUNPdy5/HRSm+Yj6okJ6UtLINN0Q9M4+h3I=
+github.com/pkg/errors v0.8.1/go.mod h1:bwawxfHBFNV+L2hUp1rHADufV3IMtnDRdf1r5NINEl0=
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err := gs.Lookup("github.com/pkg/errors", "v0.8.1/go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "github.com/pkg/errors v0.8.1/go.mod h1:bwawxfHBFNV+L2hUp1rHADufV3IMtnDRdf1r5NINEl0="; len(lines) != 1 || lines[0] != want {
+		t.Errorf("expected [%s] but got %v", want, lines)
+	}
+	if _, err := gs.Lookup("github.com/pkg/errors", "v0.9.1"); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+	if _, err := ParseGoSum(strings.NewReader("github.com/pkg/errors v0.8.1")); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	zipData := makeTestZip(t, map[string]string{
+		"github.com/pkg/errors@v0.0.1/go.mod":    testModFile,
+		"github.com/pkg/errors@v0.0.1/errors.go": "package errors\n",
+	})
+	goSum := "github.com/pkg/errors v0.0.1 " + testZipHash(t, zipData) + "\n" +
+		"github.com/pkg/errors v0.0.1/go.mod " + testModHash(t, testModFile) + "\n"
+	gs, err := ParseGoSum(strings.NewReader(goSum))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     []byte
+		wantCode int
+	}{
+		{
+			name:     "Valid mod",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.1.mod",
+			body:     []byte(testModFile),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Valid zip",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.1.zip",
+			body:     zipData,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Tampered mod",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.1.mod",
+			body:     []byte("module github.com/evil/errors\n"),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:    "Tampered zip",
+			urlPath: "/github.com/pkg/errors/@v/v0.0.1.zip",
+			body: makeTestZip(t, map[string]string{
+				"github.com/pkg/errors@v0.0.1/go.mod":    testModFile,
+				"github.com/pkg/errors@v0.0.1/errors.go": "package errors // evil\n",
+			}),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Missing checksum",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.2.mod",
+			body:     []byte(testModFile),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Excluded mod",
+			urlPath:  "/github.com/private/errors/@v/v0.0.1.mod",
+			body:     []byte("module github.com/private/errors\n"),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Excluded zip",
+			urlPath:  "/git.corp.example.com/errors/@v/v0.0.1.zip",
+			body:     []byte("zip"),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Not excluded by prefix of element",
+			urlPath:  "/github.com/privateer/errors/@v/v0.0.1.mod",
+			body:     []byte("module github.com/privateer/errors\n"),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Info is not verified",
+			urlPath:  "/github.com/pkg/errors/@v/v0.0.2.info",
+			body:     []byte(`{"Version":"v0.0.2","Time":"2019-01-02T22:52:24-08:00"}` + "\n"),
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &mockClient{
+				DoMock: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader(tt.body)),
+					}, nil
+				},
+			}
+			p, err := NewProxy(c, "https://localhost")
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := NewVerifier(gs)
+			if err := v.Exclude("*.corp.example.com,github.com/private"); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddVerifier(v); err != nil {
+				t.Fatal(err)
+			}
+			rec := serve(p, tt.urlPath)
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected %d but got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusOK && !bytes.Equal(rec.Body.Bytes(), tt.body) {
+				t.Errorf("unexpected body: %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestVerifier_Exclude(t *testing.T) {
+	v := NewVerifier(&GoSum{})
+	if err := v.Exclude(" "); err == nil {
+		t.Error("expected error for empty patterns")
+	}
+}
+
+func TestNewSumDBClient(t *testing.T) {
+	skey, vkey, err := note.GenerateKey(rand.Reader, "sum.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modHash := testModHash(t, testModFile)
+	ts := sumdb.NewTestServer(skey, func(path, vers string) ([]byte, error) {
+		if path != "github.com/pkg/errors" {
+			return nil, os.ErrNotExist
+		}
+		return []byte(path + " " + vers + " h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n" +
+			path + " " + vers + "/go.mod " + modHash + "\n"), nil
+	})
+	srv := httptest.NewServer(sumdb.NewServer(ts))
+	defer srv.Close()
+
+	c, err := NewSumDBClient(http.DefaultClient, vkey, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(c)
+	mr := &ModuleRequest{Path: "github.com/pkg/errors", Version: "v0.0.1", Kind: KindMod}
+	body, err := v.verify(mr, ioutil.NopCloser(strings.NewReader(testModFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+
+	mr = &ModuleRequest{Path: "github.com/pkg/errors", Version: "v0.0.1", Kind: KindMod}
+	if _, err := v.verify(mr, ioutil.NopCloser(strings.NewReader("module evil\n"))); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+	mr = &ModuleRequest{Path: "example.com/unknown", Version: "v0.0.1", Kind: KindMod}
+	if _, err := v.verify(mr, ioutil.NopCloser(strings.NewReader(testModFile))); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+func TestSumdbOps_ReadRemoteTimeout(t *testing.T) {
+	c := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		},
+	}
+	u, err := url.Parse("https://sum.golang.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := &sumdbOps{client: c, u: u, timeout: 10 * time.Millisecond}
+	if _, err := ops.ReadRemote("/latest"); err != context.DeadlineExceeded {
+		t.Errorf("expected %v but got %v", context.DeadlineExceeded, err)
+	}
+}
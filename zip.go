@@ -2,7 +2,6 @@ package gopp
 
 import (
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 )
@@ -10,6 +9,7 @@ import (
 // ZipProxyHandler represents proxy handler for /@v/v0.0.1.zip
 // body receieves zip file. body will close file descripter
 // at outside of the handler.
+// The h1: hash of the zip can be received by ZipHashFromContext when EnableZipHash is called.
 type ZipProxyHandler func(w http.ResponseWriter, r *http.Request, body io.Reader) error
 
 // AddZipProxyHandler registers proxy handler for /@v/v0.0.1.zip
@@ -21,17 +21,30 @@ func (p *Proxy) AddZipProxyHandler(h ZipProxyHandler) error {
 	return nil
 }
 
-func (p *Proxy) versionZipProxy(w http.ResponseWriter, r *http.Request) error {
+func defaultZipProxyHandler() ZipProxyHandler {
+	return func(w http.ResponseWriter, r *http.Request, body io.Reader) error {
+		w.Header().Set("Content-Type", "application/zip")
+		_, err := io.Copy(w, body)
+		return err
+	}
+}
+
+func (p *Proxy) versionZipProxy(w http.ResponseWriter, r *http.Request, mr *ModuleRequest) error {
 	// /golang.org/x/net/@v/v0.0.1.zip
-	resp, err := p.request(r.URL.Path)
+	body, err := p.fetch(r.Context(), mr)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %s", resp.Status)
+	if p.zipHash {
+		sf, hash, err := p.hashZip(r.Context(), mr, body)
+		if err != nil {
+			return err
+		}
+		body = sf
+		r = withZipHash(r, hash)
 	}
-	if err := p.versionZipHandler(w, r, resp.Body); err != nil {
+	defer body.Close()
+	if err := p.versionZipHandler(w, r, body); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,74 @@
+package gopp
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// EnableZipHash enables computing the h1: hash of every proxied zip.
+// The hash is passed to ZipProxyHandler by the context and served at
+// /@v/v0.0.1.ziphash like v0.0.1.ziphash of the go command's download cache.
+// The zip is spooled to a temporary file to be hashed.
+func (p *Proxy) EnableZipHash() {
+	p.zipHash = true
+}
+
+type zipHashKey struct{}
+
+// ZipHashFromContext returns the h1: hash of the zip like "h1:...".
+// ctx is expected to be the context of *http.Request passed to ZipProxyHandler.
+func ZipHashFromContext(ctx context.Context) (string, bool) {
+	hash, ok := ctx.Value(zipHashKey{}).(string)
+	return hash, ok
+}
+
+func withZipHash(r *http.Request, hash string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), zipHashKey{}, hash))
+}
+
+// hashZip computes the hash of the zip of mr and stores it to the cache.
+// body is closed by hashZip and returned body should be used instead of it.
+func (p *Proxy) hashZip(ctx context.Context, mr *ModuleRequest, body io.ReadCloser) (*spoolFile, string, error) {
+	sf, err := spool(body)
+	if err != nil {
+		return nil, "", err
+	}
+	hash, err := dirhash.HashZip(sf.Name(), dirhash.Hash1)
+	if err != nil {
+		sf.Close()
+		return nil, "", err
+	}
+	if p.cache != nil {
+		hashReq := &ModuleRequest{Path: mr.Path, Version: mr.Version, Kind: KindZipHash}
+		p.cache.put(ctx, hashReq, []byte(hash))
+	}
+	return sf, hash, nil
+}
+
+func (p *Proxy) zipHashProxy(w http.ResponseWriter, r *http.Request, mr *ModuleRequest) error {
+	// /golang.org/x/net/@v/v0.0.1.ziphash
+	if p.cache != nil {
+		if body, ok := p.cache.get(r.Context(), mr); ok {
+			defer body.Close()
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, err := io.Copy(w, body)
+			return err
+		}
+	}
+	zipReq := &ModuleRequest{Path: mr.Path, Version: mr.Version, Kind: KindZip}
+	body, err := p.fetch(r.Context(), zipReq)
+	if err != nil {
+		return err
+	}
+	sf, hash, err := p.hashZip(r.Context(), zipReq, body)
+	if err != nil {
+		return err
+	}
+	sf.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err = io.WriteString(w, hash)
+	return err
+}
@@ -0,0 +1,93 @@
+package gopp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestProxy_zipHash(t *testing.T) {
+	data := makeTestZip(t, map[string]string{
+		"github.com/pkg/errors@v0.9.1/errors.go": "package errors\n",
+	})
+	want := testZipHash(t, data)
+
+	s := NewMemoryStorage()
+	p, _, calls := newCacheTestProxy(t, s, time.Minute, http.StatusOK, string(data))
+
+	if rec := serve(p, "/github.com/pkg/errors/@v/v0.9.1.ziphash"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected %d before EnableZipHash but got %d", http.StatusNotFound, rec.Code)
+	}
+
+	p.EnableZipHash()
+	for i := 0; i < 2; i++ {
+		rec := serve(p, "/github.com/pkg/errors/@v/v0.9.1.ziphash")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %d but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		if rec.Body.String() != want {
+			t.Errorf("expected %q but got %q", want, rec.Body.String())
+		}
+	}
+	if *calls != 1 {
+		t.Errorf("expected the zip is requested once but %d times", *calls)
+	}
+	// the zip which is fetched for .ziphash is cached too.
+	body, err := s.Get(context.Background(), "github.com/pkg/errors/@v/v0.9.1.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+
+	var gotHash string
+	if err := p.AddZipProxyHandler(func(w http.ResponseWriter, r *http.Request, body io.Reader) error {
+		gotHash, _ = ZipHashFromContext(r.Context())
+		_, err := io.Copy(ioutil.Discard, body)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve(p, "/github.com/pkg/errors/@v/v0.9.1.zip"); rec.Code != http.StatusOK {
+		t.Fatalf("expected %d but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if gotHash != want {
+		t.Errorf("expected %q but got %q", want, gotHash)
+	}
+}
+
+func TestProxy_zipHashWithoutCache(t *testing.T) {
+	data := makeTestZip(t, map[string]string{
+		"github.com/pkg/errors@v0.9.1/errors.go": "package errors\n",
+	})
+	c := &mockClient{
+		DoMock: func(req *http.Request) (*http.Response, error) {
+			if got, want := req.URL.Path, "/github.com/pkg/errors/@v/v0.9.1.zip"; got != want {
+				t.Errorf("expected %s but got %s", want, got)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(data)),
+			}, nil
+		},
+	}
+	p, err := NewProxy(c, "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.EnableZipHash()
+	rec := serve(p, "/github.com/pkg/errors/@v/v0.9.1.ziphash")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if want := testZipHash(t, data); rec.Body.String() != want {
+		t.Errorf("expected %q but got %q", want, rec.Body.String())
+	}
+
+	if _, ok := ZipHashFromContext(context.Background()); ok {
+		t.Error("unexpected hash in the context")
+	}
+}